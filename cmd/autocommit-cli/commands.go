@@ -0,0 +1,407 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/spf13/cobra"
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/exitcode"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/hook"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/runctx"
+)
+
+// modeFlags holds the global flags that select how a run behaves.
+type modeFlags struct {
+	ci      bool
+	review  bool
+	noPush  bool
+	noAI    bool
+	verbose bool
+	dryRun  bool
+}
+
+// modeFlagNames maps each mode flag to the config key it overrides. Flags
+// without a config key (dry-run) only affect the current run.
+var modeFlagNames = map[string]string{
+	"ci":      "ci",
+	"review":  "review_mode",
+	"no-push": "auto_push",
+	"no-ai":   "ai_commit",
+	"verbose": "verbose",
+	"dry-run": "",
+}
+
+// session is the state shared by the commands of a single invocation.
+type session struct {
+	cfg  config.Config
+	mode AppMode
+	log  logger.Logger
+	// stages records the pipeline stages completed so far, for the dry-run report.
+	stages []string
+}
+
+// stage records a completed pipeline stage.
+func (s *session) stage(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	s.stages = append(s.stages, msg)
+	s.log.Debug("Stage: %s", msg)
+}
+
+// finish ends the invocation: it logs the final result line, carrying the
+// exit code and result name for the error's kind, and exits.
+func (s *session) finish(err error) {
+	kind := exitcode.KindOf(err)
+	switch {
+	case err == nil:
+		s.log.Result(kind.Code(), kind.String(), "Done.")
+	case kind == exitcode.NoChanges:
+		s.log.Result(kind.Code(), kind.String(), "%v", err)
+	default:
+		s.log.Result(kind.Code(), kind.String(), "Error: %v", err)
+	}
+}
+
+// newSession loads the layered configuration, resolves the run mode and
+// installs the run context that enforces its invariants. The interactive
+// mode menu is only shown for `run` when stdin is a terminal, no mode flag
+// was given and CI mode is off.
+func newSession(cmd *cobra.Command, flags *modeFlags, interactive bool) *session {
+	_ = godotenv.Load()
+
+	overrides := map[string]string{}
+	given := false
+	for name, key := range modeFlagNames {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || !f.Changed {
+			continue
+		}
+		given = true
+		if key == "" {
+			continue
+		}
+		value := f.Value.String()
+		if strings.HasPrefix(name, "no-") {
+			b, _ := strconv.ParseBool(value)
+			value = strconv.FormatBool(!b)
+		}
+		overrides[key] = value
+	}
+
+	cfg, err := config.LoadConfig(overrides)
+	if err != nil {
+		logger.NewHumanReadableLogger(false).Fatal(1, "Could not load config: %v", err)
+	}
+
+	s := &session{cfg: cfg}
+	runctx.Set(runctx.Context{CI: cfg.CI, DryRun: flags.dryRun})
+	if cfg.CI {
+		s.mode = AppMode{CI: true, AICommit: cfg.AICommit, NoPush: !cfg.AutoPush, Verbose: cfg.Verbose, DryRun: flags.dryRun, AILowConfidence: cfg.AILowConfidence && !flags.noAI}
+		s.log = logger.NewJSONLogger(cfg.Verbose)
+		return s
+	}
+
+	s.log = logger.NewHumanReadableLogger(cfg.Verbose || flags.verbose)
+	if interactive && !given && isTerminal(os.Stdin) {
+		if err := printWelcomeMessage(); err != nil {
+			s.finish(err)
+		}
+		if s.mode, err = promptForMode(); err != nil {
+			s.finish(err)
+		}
+	} else {
+		// --no-ai rules out every AI call, including the opt-in one.
+		s.mode = AppMode{AICommit: cfg.AICommit, AILowConfidence: cfg.AILowConfidence && !flags.noAI}
+	}
+	s.mode.Review = s.mode.Review || cfg.ReviewMode
+	s.mode.NoPush = s.mode.NoPush || !cfg.AutoPush
+	s.mode.Verbose = s.mode.Verbose || cfg.Verbose
+	s.mode.DryRun = flags.dryRun
+	s.log = logger.NewHumanReadableLogger(s.mode.Verbose)
+	return s
+}
+
+func newRootCmd() *cobra.Command {
+	flags := &modeFlags{}
+
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Plan, commit and push the working tree changes (default)",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s := newSession(cmd, flags, true)
+			s.finish(runAutocommit(s))
+		},
+	}
+
+	root := &cobra.Command{
+		Use:           "autocommit-cli",
+		Short:         "autocommit-cli — commit smarter, not harder",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		Run:           runCmd.Run,
+	}
+
+	pf := root.PersistentFlags()
+	pf.BoolVar(&flags.ci, "ci", false, "Run in CI mode (non-interactive, JSON logs)")
+	pf.BoolVar(&flags.review, "review", false, "Review and edit each commit before it is made")
+	pf.BoolVar(&flags.noPush, "no-push", false, "Create commits but do not push them")
+	pf.BoolVar(&flags.noAI, "no-ai", false, "Use rule-based messages instead of AI")
+	pf.BoolVar(&flags.verbose, "verbose", false, "Print debug output")
+	pf.BoolVar(&flags.dryRun, "dry-run", false, "Show what would be committed without changing anything")
+
+	root.AddCommand(
+		runCmd,
+		newPlanCmd(flags),
+		&cobra.Command{
+			Use:   "apply",
+			Short: "Execute the plan saved by `plan`",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				s := newSession(cmd, flags, false)
+				s.finish(runApply(s))
+			},
+		},
+		&cobra.Command{
+			Use:   "undo",
+			Short: "Undo the commits made by the last run, keeping the changes",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				s := newSession(cmd, flags, false)
+				s.finish(runUndo(s))
+			},
+		},
+		&cobra.Command{
+			Use:   "learn",
+			Short: "Rebuild learned scopes and types from commit history",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				s := newSession(cmd, flags, false)
+				s.finish(runLearn(s))
+			},
+		},
+		&cobra.Command{
+			Use:   "doctor",
+			Short: "Check the repository, configuration and environment",
+			Args:  cobra.NoArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				s := newSession(cmd, flags, false)
+				s.finish(runDoctor(s))
+			},
+		},
+		&cobra.Command{
+			Use:   "lint [file|range]",
+			Short: "Validate commit messages against the compiled commit rules",
+			Long: "Lint a commit message file (as in a commit-msg hook), a revision, or a revision range.\n" +
+				"Without an argument the HEAD commit is linted.",
+			Args: cobra.MaximumNArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				s := newSession(cmd, flags, false)
+				s.finish(runLint(s, args))
+			},
+		},
+		newStatsCmd(flags),
+		newConfigCmd(flags),
+		newHookCmd(flags),
+	)
+
+	return root
+}
+
+func newPlanCmd(flags *modeFlags) *cobra.Command {
+	var explain bool
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Compute and save the commit plan without committing",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s := newSession(cmd, flags, false)
+			s.finish(runPlan(s, explain))
+		},
+	}
+	cmd.Flags().BoolVar(&explain, "explain", false, "Show the confidence and signals behind each commit")
+	return cmd
+}
+
+func newStatsCmd(flags *modeFlags) *cobra.Command {
+	var asJSON bool
+	var top int
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Report commit types, scopes, sizes, compliance and autocommit share",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s := newSession(cmd, flags, false)
+			err := runStats(s, asJSON, top)
+			if err == nil && asJSON {
+				// Keep stdout a single JSON document.
+				s.log.Result(exitcode.Success.Code(), exitcode.Success.String(), "")
+			}
+			s.finish(err)
+		},
+	}
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	cmd.Flags().IntVar(&top, "top", 10, "Number of scopes to show")
+	return cmd
+}
+
+func newConfigCmd(flags *modeFlags) *cobra.Command {
+	var origin bool
+	show := &cobra.Command{
+		Use:   "show",
+		Short: "Print the effective configuration",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			s := newSession(cmd, flags, false)
+			for _, key := range config.Keys() {
+				if origin {
+					s.log.Info("%-20s = %-6s (%s)", key, s.cfg.Get(key), s.cfg.Origins[key])
+				} else {
+					s.log.Info("%s = %s", key, s.cfg.Get(key))
+				}
+			}
+			s.finish(nil)
+		},
+	}
+	show.Flags().BoolVar(&origin, "origin", false, "Show which layer set each value")
+
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect configuration",
+	}
+	configCmd.AddCommand(show)
+	return configCmd
+}
+
+// newHookCmd builds `autocommit hook ...`. The install form sets up a git
+// hook; the other forms are invoked by git itself. A failing
+// prepare-commit-msg hook never blocks the commit: the developer just gets
+// the usual empty message. A commit-msg hook rejects messages that break the
+// compiled commit rules.
+func newHookCmd(flags *modeFlags) *cobra.Command {
+	var force bool
+	install := &cobra.Command{
+		Use:       "install [" + hook.PrepareCommitMsg + "|" + hook.CommitMsg + "]",
+		Short:     "Install a git hook that calls autocommit",
+		Args:      cobra.MaximumNArgs(1),
+		ValidArgs: []string{hook.PrepareCommitMsg, hook.CommitMsg},
+		Run: func(cmd *cobra.Command, args []string) {
+			s := newSession(cmd, flags, false)
+			name := hook.PrepareCommitMsg
+			if len(args) > 0 {
+				name = args[0]
+			}
+			path, err := hook.Install(s.log, name, force)
+			if err != nil {
+				s.finish(fmt.Errorf("hook install failed: %w", err))
+			}
+			s.log.Info("Installed %s hook at %s", name, path)
+			s.finish(nil)
+		},
+	}
+	install.Flags().BoolVar(&force, "force", false, "Replace an existing hook not installed by autocommit")
+
+	hookCmd := &cobra.Command{
+		Use:   "hook",
+		Short: "Manage and run git hooks",
+	}
+	hookCmd.AddCommand(
+		install,
+		&cobra.Command{
+			Use:    hook.PrepareCommitMsg + " <msg-file> [source] [sha]",
+			Short:  "Run the prepare-commit-msg hook (called by git)",
+			Hidden: true,
+			Args:   cobra.RangeArgs(1, 3),
+			Run: func(cmd *cobra.Command, args []string) {
+				s := newSession(cmd, flags, false)
+				if err := hook.RunPrepareCommitMsg(s.log, s.cfg, args); err != nil {
+					s.log.Error("autocommit: could not generate commit message: %v", err)
+				}
+			},
+		},
+		&cobra.Command{
+			Use:    hook.CommitMsg + " <msg-file>",
+			Short:  "Run the commit-msg hook (called by git)",
+			Hidden: true,
+			Args:   cobra.ExactArgs(1),
+			Run: func(cmd *cobra.Command, args []string) {
+				s := newSession(cmd, flags, false)
+				s.finish(runLint(s, args))
+			},
+		},
+	)
+	return hookCmd
+}
+
+// runLint lints a commit message file or the commits selected by a
+// revision or range, skipping the messages commitlint ignores by default
+// (merges, reverts, fixups and squashes). It returns a RuleViolation error if any message has errors.
+func runLint(s *session, args []string) error {
+	logg := s.log
+
+	rules, err := config.LoadCommitRules(s.cfg)
+	if err != nil {
+		return fmt.Errorf("could not load commit rules: %w", err)
+	}
+
+	target := "HEAD"
+	if len(args) > 0 {
+		target = args[0]
+	}
+
+	var commits []git.CommitMessage
+	if info, statErr := os.Stat(target); statErr == nil && !info.IsDir() {
+		content, err := ioutil.ReadFile(target)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", target, err)
+		}
+		commits = []git.CommitMessage{{Hash: target, Message: lint.CleanMessage(string(content))}}
+	} else {
+		logArgs := []string{target}
+		if !strings.Contains(target, "..") && !strings.ContainsAny(target, "^@") {
+			logArgs = []string{"-1", target}
+		}
+		commits, err = git.CommitMessages(logg, logArgs...)
+		if err != nil {
+			return err
+		}
+	}
+
+	failed, ignored := 0, 0
+	for _, c := range commits {
+		if lint.Ignored(c.Message) {
+			logg.Debug("Not linting %s, ignored by default: %s", c.Hash, strings.SplitN(c.Message, "\n", 2)[0])
+			ignored++
+			continue
+		}
+		violations := lint.Message(rules, c.Message)
+		if len(violations) == 0 {
+			continue
+		}
+		if len(lint.Errors(violations)) > 0 {
+			failed++
+		}
+		name := c.Hash
+		if len(name) == 40 {
+			name = name[:7]
+		}
+		logg.Error("%s: %s", name, strings.SplitN(c.Message, "\n", 2)[0])
+		for _, v := range violations {
+			logg.Error("  ✖ %s", v)
+		}
+	}
+
+	if failed > 0 {
+		return exitcode.New(exitcode.RuleViolation, "%d of %d commit message(s) failed lint", failed, len(commits))
+	}
+	logg.Info("%d commit message(s) passed lint, %d ignored", len(commits)-ignored, ignored)
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/hook"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+// runDoctor checks everything a run depends on and reports each result.
+// It fails if any required check fails; optional checks only warn.
+func runDoctor(s *session) error {
+	failed := false
+	report := func(ok, required bool, name, detail string) {
+		switch {
+		case ok:
+			s.log.Info("✔ %s", name)
+		case required:
+			failed = true
+			s.log.Error("✖ %s: %s", name, detail)
+		default:
+			s.log.Info("⚠ %s: %s", name, detail)
+		}
+	}
+
+	out, err := exec.Command("git", "--version").Output()
+	report(err == nil, true, "git installed", "git was not found on PATH")
+	if err == nil {
+		s.log.Debug("%s", strings.TrimSpace(string(out)))
+	}
+
+	_, err = git.StateDir()
+	report(err == nil, true, "inside a git repository", errString(err))
+
+	err = git.CheckGitStatus(logger.NewHumanReadableLogger(false))
+	report(err == nil, true, "repository state", errString(err))
+
+	report(os.Getenv("GEMINI_API_KEY") != "", s.cfg.AICommit, "GEMINI_API_KEY set", "AI commit messages are unavailable; use --no-ai or set GEMINI_API_KEY")
+
+	for _, key := range config.Keys() {
+		s.log.Debug("config %s = %s (%s)", key, s.cfg.Get(key), s.cfg.Origins[key])
+	}
+	report(true, true, "configuration loaded", "")
+
+	_, err = config.LoadCommitRules(s.cfg)
+	report(err == nil, true, "commit rules", errString(err))
+
+	if dir, err := git.HooksDir(); err == nil {
+		for _, name := range []string{hook.PrepareCommitMsg, hook.CommitMsg} {
+			content, err := ioutil.ReadFile(filepath.Join(dir, name))
+			installed := err == nil && strings.Contains(string(content), "autocommit")
+			report(installed, false, name+" hook installed", "run `autocommit-cli hook install "+name+"`")
+		}
+	}
+
+	if failed {
+		return fmt.Errorf("some checks failed")
+	}
+	return nil
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -2,26 +2,23 @@ package main
 
 import (
 	"bufio"
-	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"strings"
 
-	"github.com/joho/godotenv"
-	"github.com/urstruelysv/autocommit-cli/internal/ai"
-	"github.com/urstruelysv/autocommit-cli/internal/classify"
-	"github.com/urstruelysv/autocommit-cli/internal/git"
-	"github.com/urstruelysv/autocommit-cli/internal/history"
-	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/runctx"
 )
 
 /*
 Pixel-style ASCII inspired by Press Start 2P.
 Terminal-safe. No invalid escapes.
 */
-func printWelcomeMessage() {
+func printWelcomeMessage() error {
+	if err := runctx.Require(runctx.Banner); err != nil {
+		return err
+	}
 	brightRed := "\033[91m"
 	reset := "\033[0m"
 
@@ -35,11 +32,12 @@ func printWelcomeMessage() {
 `
 
 	fmt.Printf("%s%s%s\n", brightRed, ascii, reset)
-	fmt.Println("autocommit-cli — commit smarter, not harder\n")
+	fmt.Print("autocommit-cli — commit smarter, not harder\n\n")
 	fmt.Println("Tips:")
 	fmt.Println("  • Press Enter to use AI-Commit (default)")
-	fmt.Println("  • Use --ci for non-interactive mode")
-	fmt.Println("  • Add GEMINI_API_KEY to your .env file\n")
+	fmt.Println("  • Use --ci for non-interactive mode, or run `autocommit-cli --help`")
+	fmt.Print("  • Add GEMINI_API_KEY to your .env file\n\n")
+	return nil
 }
 
 type AppMode struct {
@@ -48,9 +46,16 @@ type AppMode struct {
 	CI       bool
 	Verbose  bool
 	AICommit bool
+	DryRun   bool
+	// AILowConfidence asks the AI for the messages of low-confidence
+	// groups when AICommit is off.
+	AILowConfidence bool
 }
 
-func promptForMode() AppMode {
+func promptForMode() (AppMode, error) {
+	if err := runctx.Require(runctx.StdinRead); err != nil {
+		return AppMode{}, err
+	}
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println("Select a mode (default: AI-Commit):")
@@ -72,108 +77,32 @@ func promptForMode() AppMode {
 
 		switch strings.TrimSpace(input) {
 		case "", "1":
-			return AppMode{AICommit: true}
+			return AppMode{AICommit: true}, nil
 		case "2":
-			return AppMode{}
+			return AppMode{}, nil
 		case "3":
-			return AppMode{Review: true, AICommit: true}
+			return AppMode{Review: true, AICommit: true}, nil
 		case "4":
-			return AppMode{NoPush: true, AICommit: true}
+			return AppMode{NoPush: true, AICommit: true}, nil
 		case "5":
-			return AppMode{Verbose: true, AICommit: true}
+			return AppMode{Verbose: true, AICommit: true}, nil
 		default:
 			fmt.Print("Invalid choice. Enter 1–5: ")
 		}
 	}
 }
 
-func main() {
-	printWelcomeMessage()
-
-	ciFlag := flag.Bool("ci", false, "Run in CI mode")
-	flag.Parse()
-
-	_ = godotenv.Load()
-
-	var appMode AppMode
-	var logg logger.Logger
-
-	if *ciFlag {
-		appMode = AppMode{CI: true, AICommit: true}
-		logg = logger.NewJSONLogger()
-	} else {
-		appMode = promptForMode()
-		logg = logger.NewHumanReadableLogger()
-	}
-
-	logg.Info("autocommit-cli started")
-
-	if err := git.CheckGitStatus(logg); err != nil {
-		logg.Fatal(1, "Git status check failed: %v", err)
-	}
-
-	learnedData, err := history.LoadLearnedData(logg)
-	if err != nil {
-		learnedData = history.LearnFromHistory(logg)
-		_ = history.SaveLearnedData(logg, learnedData)
-	}
-
-	changes, err := git.DetectChanges(logg)
+// isTerminal reports whether f is attached to an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
 	if err != nil {
-		logg.Fatal(1, "Change detection failed: %v", err)
-	}
-
-	if changes == "" {
-		logg.Info("No changes detected. Clean working tree.")
-		return
-	}
-
-	if appMode.AICommit {
-		if os.Getenv("GEMINI_API_KEY") == "" {
-			logg.Fatal(1, "GEMINI_API_KEY not set")
-		}
-
-		message, err := ai.GenerateAICommitMessage(logg, changes)
-		if err != nil {
-			logg.Fatal(1, "AI commit failed: %v", err)
-		}
-
-		if err := git.CommitChanges(logg, message, []string{"--all"}); err != nil {
-			logg.Fatal(1, "Commit failed: %v", err)
-		}
-
-		if !appMode.NoPush {
-			_ = git.PushChanges(logg)
-		}
-		return
-	}
-
-	// Non-AI path
-	groups := classify.ClassifyAndGroupChanges(logg, changes, learnedData)
-
-	summaries := map[string]string{
-		"feat":     "add new functionality",
-		"fix":      "fix bugs",
-		"docs":     "update documentation",
-		"chore":    "maintenance",
-		"refactor": "refactor code",
-		"test":     "update tests",
-	}
-
-	for groupKey, files := range groups {
-		commitType := groupKey
-		if i := strings.Index(groupKey, "("); i != -1 {
-			commitType = groupKey[:i]
-		}
-
-		message := fmt.Sprintf("%s: %s", groupKey, summaries[commitType])
-
-		if err := git.CommitChanges(logg, message, files); err != nil {
-			logg.Fatal(1, "Commit failed: %v", err)
-		}
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
 
-	if !appMode.NoPush {
-		_ = git.PushChanges(logg)
+func main() {
+	if err := newRootCmd().Execute(); err != nil {
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/exitcode"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+	"github.com/urstruelysv/autocommit-cli/internal/plan"
+	"github.com/urstruelysv/autocommit-cli/internal/runctx"
+)
+
+// reviewPlan walks through the planned commits and lets the user accept,
+// edit or skip each one. An edited message is the user's: it is checked
+// against the commit rules and warnings are shown, but it is never repaired
+// or regenerated.
+func reviewPlan(s *session, p plan.Plan) (plan.Plan, error) {
+	if err := runctx.Require(runctx.StdinRead); err != nil {
+		return p, err
+	}
+	reader := bufio.NewReader(os.Stdin)
+	rules, _ := config.LoadCommitRules(s.cfg)
+
+	var kept []plan.CommitPlan
+	for i, c := range p.Commits {
+		fmt.Printf("\n--- Review commit %d of %d ---\n", i+1, len(p.Commits))
+		fmt.Printf("%s\n\nFiles: %s\n", c.Message, strings.Join(c.Files, ", "))
+
+		for {
+			fmt.Print("[a]ccept, [e]dit, [s]kip, [q]uit (default a): ")
+			input, err := reader.ReadString('\n')
+			if err != nil {
+				return p, fmt.Errorf("review aborted: %w", err)
+			}
+
+			switch strings.ToLower(strings.TrimSpace(input)) {
+			case "", "a":
+				kept = append(kept, c)
+			case "e":
+				message, err := editMessage(reader, c.Message)
+				if err != nil {
+					s.log.Error("Edit failed: %v", err)
+					continue
+				}
+				for _, v := range lint.Message(rules, message) {
+					s.log.Error("  ⚠ %s", v)
+				}
+				c.Message = message
+				kept = append(kept, c)
+			case "s":
+				s.log.Info("Skipped: %s", strings.SplitN(c.Message, "\n", 2)[0])
+			case "q":
+				return p, exitcode.New(exitcode.NoChanges, "Review cancelled; nothing was committed.")
+			default:
+				continue
+			}
+			break
+		}
+	}
+
+	p.Commits = kept
+	return p, nil
+}
+
+// editMessage opens the message in $EDITOR, or asks for a replacement
+// header on stdin when no editor is configured.
+func editMessage(reader *bufio.Reader, message string) (string, error) {
+	if err := runctx.Require(runctx.Editor); err != nil {
+		return "", err
+	}
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		fmt.Print("New commit message: ")
+		input, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		if input = strings.TrimSpace(input); input != "" {
+			return input, nil
+		}
+		return message, nil
+	}
+
+	tmp, err := ioutil.TempFile("", "autocommit-msg-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(message + "\n"); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	tmp.Close()
+
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", tmp.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s exited with error: %w", editor, err)
+	}
+
+	edited, err := ioutil.ReadFile(tmp.Name())
+	if err != nil {
+		return "", err
+	}
+	if cleaned := lint.CleanMessage(string(edited)); cleaned != "" {
+		return cleaned, nil
+	}
+	return message, nil
+}
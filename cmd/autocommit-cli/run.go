@@ -0,0 +1,528 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/ai"
+	"github.com/urstruelysv/autocommit-cli/internal/classify"
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/exitcode"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/history"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/plan"
+	"github.com/urstruelysv/autocommit-cli/internal/prompt"
+	"github.com/urstruelysv/autocommit-cli/internal/runctx"
+	"github.com/urstruelysv/autocommit-cli/internal/secrets"
+)
+
+// summaries are the rule-based subjects used when AI is disabled. Custom
+// types from the config add to them.
+var summaries = map[string]string{
+	"feat":     "add new functionality",
+	"fix":      "fix bugs",
+	"docs":     "update documentation",
+	"style":    "format code",
+	"refactor": "refactor code",
+	"perf":     "improve performance",
+	"test":     "update tests",
+	"build":    "update build configuration",
+	"ci":       "update CI workflows",
+	"chore":    "maintenance",
+	"revert":   "revert earlier changes",
+}
+
+// runAutocommit is the default command: plan, optionally review, commit and push.
+func runAutocommit(s *session) error {
+	s.log.Info("autocommit-cli started")
+
+	p, err := buildPlan(s)
+	if err != nil {
+		return err
+	}
+	return executePlan(s, p)
+}
+
+// runPlan computes the plan and saves it for a later `apply`. With explain
+// it shows how every commit was classified.
+func runPlan(s *session, explain bool) error {
+	p, err := buildPlan(s)
+	if err != nil {
+		return err
+	}
+	if s.mode.DryRun {
+		if explain {
+			printPlan(s, p, true)
+		}
+		s.printReport(&p)
+		return nil
+	}
+	printPlan(s, p, explain)
+
+	path, err := plan.Save(p)
+	if err != nil {
+		return fmt.Errorf("could not save plan: %w", err)
+	}
+	s.log.Info("Plan saved to %s. Run `autocommit-cli apply` to execute it.", path)
+	return nil
+}
+
+// runApply executes a saved plan, refusing if the repository changed since
+// it was computed.
+func runApply(s *session) error {
+	p, err := plan.Load()
+	if err != nil {
+		return fmt.Errorf("no saved plan: %w", err)
+	}
+
+	if err := git.CheckGitStatus(s.log); err != nil {
+		return exitcode.Wrap(exitcode.UnsafeGitState, fmt.Errorf("git status check failed: %w", err))
+	}
+	head, err := git.Head()
+	if err != nil {
+		return err
+	}
+	changes, err := git.DetectChanges(s.log)
+	if err != nil {
+		return fmt.Errorf("change detection failed: %w", err)
+	}
+	if head != p.Head || changes != p.Snapshot {
+		return exitcode.New(exitcode.UnsafeGitState, "the repository changed since the plan was made; run `autocommit-cli plan` again")
+	}
+	s.stage("saved plan matches the repository state")
+
+	if err := executePlan(s, p); err != nil || s.mode.DryRun {
+		return err
+	}
+	if err := plan.Discard(); err != nil {
+		s.log.Error("%v", err)
+	}
+	return nil
+}
+
+// buildPlan validates the repository state, takes the change snapshot and
+// computes the commit plan. In AI mode the AI is called once; otherwise
+// only for low-confidence groups, and only with ai_low_confidence on. It
+// returns a NoChanges error when there is nothing to commit.
+func buildPlan(s *session) (plan.Plan, error) {
+	logg := s.log
+
+	if err := git.CheckGitStatus(logg); err != nil {
+		return plan.Plan{}, exitcode.Wrap(exitcode.UnsafeGitState, fmt.Errorf("git status check failed: %w", err))
+	}
+	s.stage("git state checks passed")
+
+	rules, err := config.LoadCommitRules(s.cfg)
+	if err != nil {
+		return plan.Plan{}, fmt.Errorf("could not load commit rules: %w", err)
+	}
+	s.stage("commit rules compiled (%d allowed type(s), header max %d)", len(rules.Types), rules.HeaderMaxLength)
+
+	learnedData, err := loadLearnedData(s, logg)
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	s.stage("learned %d scope(s) and %d type(s) from history", len(learnedData.Scopes), len(learnedData.Types))
+
+	changes, err := git.DetectChanges(logg)
+	if err != nil {
+		return plan.Plan{}, fmt.Errorf("change detection failed: %w", err)
+	}
+	if changes == "" {
+		return plan.Plan{}, exitcode.New(exitcode.NoChanges, "No changes detected. Clean working tree.")
+	}
+	files := git.ChangedFiles(changes)
+	s.stage("snapshot taken: %d changed file(s)", len(files))
+
+	// Scan before anything leaves the machine: the AI prompt lists the
+	// changed files, and low-confidence groups send their patches.
+	var findings []secrets.Finding
+	scanned, err := git.ExpandDirs(files)
+	if err != nil {
+		return plan.Plan{}, fmt.Errorf("secret scan failed: %w", err)
+	}
+	for _, file := range scanned {
+		added, err := git.AddedLines(file)
+		if err != nil {
+			return plan.Plan{}, fmt.Errorf("secret scan failed: %w", err)
+		}
+		findings = append(findings, secrets.Scan(file, added)...)
+	}
+	if len(findings) > 0 {
+		for _, f := range findings {
+			logg.Error("Possible secret: %s", f)
+		}
+		if s.mode.DryRun {
+			s.printReport(nil)
+		}
+		return plan.Plan{}, exitcode.New(exitcode.SecretDetected, "secret scan found %d possible secret(s); aborting without committing", len(findings))
+	}
+	s.stage("secret scan: no findings")
+
+	head, err := git.Head()
+	if err != nil {
+		return plan.Plan{}, err
+	}
+	p := plan.Plan{Head: head, Snapshot: changes}
+
+	if s.mode.AICommit {
+		if os.Getenv("GEMINI_API_KEY") == "" {
+			return plan.Plan{}, exitcode.New(exitcode.AIFailure, "GEMINI_API_KEY not set")
+		}
+
+		// Generated, vendored and lockfiles are named but not described.
+		handwritten, derived := classify.SeparateDerived(files)
+		omit := make(map[string]bool)
+		for _, f := range derived {
+			omit[f] = true
+		}
+		var described []string
+		for _, line := range strings.Split(changes, "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 && !omit[fields[len(fields)-1]] {
+				described = append(described, line)
+			}
+		}
+		examples := prompt.Examples(logg, prompt.QueryFor(learnedData, handwritten), s.cfg.FewShotExamples)
+		s.stage("picked %d few-shot example(s) from history", len(examples))
+		generated, err := ai.GenerateAICommitMessage(logg, prompt.Build(strings.Join(described, "\n"), learnedData.Style.Guidance(rules), examples, derived))
+		if err != nil {
+			return plan.Plan{}, exitcode.Wrap(exitcode.AIFailure, fmt.Errorf("AI commit failed: %w", err))
+		}
+		s.stage("AI generated a commit message")
+		breaking := classify.CompareAPI(files)
+		var descriptions []string
+		for _, c := range breaking {
+			descriptions = append(descriptions, c.Description)
+		}
+		generated = lint.MarkBreaking(rules, generated, descriptions)
+		message := lint.MarkBreaking(rules, lint.Enforce(logg, rules, generated), descriptions)
+
+		h, _ := lint.ParseHeader(message)
+		p.Commits = []plan.CommitPlan{{
+			Type:      h.Type,
+			Scope:     h.Scope,
+			Files:     files,
+			Message:   message,
+			Rationale: append([]string{"AI mode: all changes in one commit"}, breakingNotes(descriptions, validationNote(generated, message))...),
+		}}
+		s.stage("commit messages validated against commit rules")
+		return p, nil
+	}
+
+	// Non-AI path
+	groups := classify.ClassifyAndGroupChanges(logg, changes, learnedData, s.cfg.Rules)
+
+	var low []classify.Group
+	for _, g := range groups {
+		// Partial commits must stay in order, so they are never regrouped.
+		if g.Confidence < s.cfg.MinConfidence && len(g.Content) == 0 {
+			low = append(low, g)
+			continue
+		}
+		p.Commits = append(p.Commits, groupCommit(s, g, learnedData, rules))
+	}
+	if len(low) > 0 {
+		p.Commits = append(p.Commits, lowConfidenceCommits(s, low, changes, learnedData, rules)...)
+	}
+	s.stage("classified changes into %d group(s)", len(p.Commits))
+	s.stage("commit messages validated against commit rules")
+	return p, nil
+}
+
+// groupCommit plans the commit for a group with a rule-based message.
+func groupCommit(s *session, g classify.Group, learnedData history.LearnData, rules config.CommitRules) plan.CommitPlan {
+	summary, ok := s.cfg.Types[g.Type]
+	if !ok {
+		summary = summaries[g.Type]
+	}
+	generated := fmt.Sprintf("%s: %s", g.Key(), summary)
+	// Phrase the subject like the rest of the log, unless that style
+	// breaks the commit rules.
+	if styled := fmt.Sprintf("%s: %s", g.Key(), learnedData.Style.Phrase(rules, g.Type, summary)); len(lint.Errors(lint.Message(rules, styled))) == 0 {
+		generated = styled
+	}
+	// The breaking marker survives validation, even a fallback message.
+	generated = lint.MarkBreaking(rules, generated, g.Breaking)
+	message := lint.MarkBreaking(rules, lint.Enforce(s.log, rules, generated), g.Breaking)
+	return plan.CommitPlan{
+		Type:       g.Type,
+		Scope:      g.Scope,
+		Files:      g.Files,
+		Content:    g.Content,
+		Message:    message,
+		Rationale:  append(g.Reasons, breakingNotes(g.Breaking, validationNote(generated, message))...),
+		Confidence: g.Confidence,
+		Signals:    g.Signals,
+	}
+}
+
+// lowConfidenceCommits plans the groups classified with less than
+// min_confidence. With ai_low_confidence on, the AI writes each group's
+// message from the group's own patches. Otherwise, or when the AI fails,
+// they keep their rule-based messages, and an interactive run reviews the
+// plan.
+func lowConfidenceCommits(s *session, low []classify.Group, changes string, learnedData history.LearnData, rules config.CommitRules) []plan.CommitPlan {
+	logg := s.log
+	useAI := s.mode.AILowConfidence
+	if useAI && os.Getenv("GEMINI_API_KEY") == "" {
+		logg.Error("ai_low_confidence is on but GEMINI_API_KEY is not set; using rule-based messages")
+		useAI = false
+	}
+	var patches git.Patches
+	if useAI {
+		var err error
+		if patches, err = git.SnapshotDiff(logg, git.UntrackedFiles(changes)); err != nil {
+			logg.Error("Could not read the diff for low-confidence groups; using rule-based messages: %v", err)
+			useAI = false
+		}
+	}
+
+	var commits []plan.CommitPlan
+	review := false
+	for _, g := range low {
+		note := fmt.Sprintf("%s: confidence %.2f is below min_confidence %.2f", g.Key(), g.Confidence, s.cfg.MinConfidence)
+		if useAI {
+			c, err := aiGroupCommit(s, g, patches, learnedData, rules)
+			if err == nil {
+				s.stage("AI wrote the message for low-confidence group '%s'", g.Key())
+				c.Rationale = append([]string{note}, c.Rationale...)
+				commits = append(commits, c)
+				continue
+			}
+			logg.Error("AI could not write the message for '%s'; using the rule-based message: %v", g.Key(), err)
+		}
+		review = true
+		c := groupCommit(s, g, learnedData, rules)
+		c.Rationale = append([]string{note}, c.Rationale...)
+		commits = append(commits, c)
+	}
+	if review && !s.mode.CI && isTerminal(os.Stdin) && !s.mode.Review {
+		s.mode.Review = true
+		s.stage("%d group(s) below min_confidence; the plan will be reviewed", len(low))
+	}
+	return commits
+}
+
+// aiGroupCommit plans the commit for a group with a message the AI writes
+// from the group's patches.
+func aiGroupCommit(s *session, g classify.Group, patches git.Patches, learnedData history.LearnData, rules config.CommitRules) (plan.CommitPlan, error) {
+	handwritten, derived := classify.SeparateDerived(g.Files)
+	var diff []string
+	for _, f := range handwritten {
+		diff = append(diff, patches.For(f))
+	}
+	examples := prompt.Examples(s.log, prompt.QueryFor(learnedData, handwritten), s.cfg.FewShotExamples)
+	generated, err := ai.GenerateAICommitMessage(s.log, prompt.Build(strings.Join(diff, ""), learnedData.Style.Guidance(rules), examples, derived))
+	if err != nil {
+		return plan.CommitPlan{}, err
+	}
+	generated = lint.MarkBreaking(rules, generated, g.Breaking)
+	message := lint.MarkBreaking(rules, lint.Enforce(s.log, rules, generated), g.Breaking)
+	h, _ := lint.ParseHeader(message)
+	return plan.CommitPlan{
+		Type:       h.Type,
+		Scope:      h.Scope,
+		Files:      g.Files,
+		Message:    message,
+		Rationale:  append(append(g.Reasons, "message written by AI"), breakingNotes(g.Breaking, validationNote(generated, message))...),
+		Confidence: g.Confidence,
+		Signals:    g.Signals,
+	}, nil
+}
+
+// loadLearnedData loads the learned data and, when learning from history is
+// on, brings it up to date with HEAD, saving it if the run allows.
+func loadLearnedData(s *session, logg logger.Logger) (history.LearnData, error) {
+	learnedData, err := history.LoadLearnedData(logg)
+	if err != nil {
+		logg.Debug("No usable learned data: %v", err)
+	}
+	if !s.cfg.LearnFromHistory {
+		return learnedData, nil
+	}
+	learnedData, changed := history.UpdateFromHistory(logg, learnedData)
+	if changed && runctx.Allows(runctx.CacheWrite) {
+		if err := history.SaveLearnedData(logg, learnedData); err != nil {
+			return learnedData, err
+		}
+	}
+	return learnedData, nil
+}
+
+// breakingNotes prefixes the rationale notes with one line per breaking
+// API change.
+func breakingNotes(breaking []string, notes ...string) []string {
+	var out []string
+	for _, b := range breaking {
+		out = append(out, "breaking: "+b)
+	}
+	return append(out, notes...)
+}
+
+// validationNote describes what rule validation did to a generated message.
+func validationNote(generated, message string) string {
+	switch {
+	case message == strings.TrimSpace(generated):
+		return "message passed commit rules"
+	case message == lint.FallbackMessage:
+		return "message failed commit rules; using fallback"
+	default:
+		return "message repaired to satisfy commit rules"
+	}
+}
+
+// executePlan reviews (when enabled), commits each planned group in order
+// and pushes. In dry-run mode it prints the report instead.
+func executePlan(s *session, p plan.Plan) error {
+	logg := s.log
+
+	if s.mode.Review && !s.mode.CI {
+		var err error
+		if p, err = reviewPlan(s, p); err != nil {
+			return err
+		}
+		if len(p.Commits) == 0 {
+			return exitcode.New(exitcode.NoChanges, "All commits were skipped; nothing was committed.")
+		}
+	}
+
+	if s.mode.DryRun {
+		s.printReport(&p)
+		return nil
+	}
+
+	before, err := git.Head()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range p.Commits {
+		if err := git.CommitChanges(logg, c.Message, c.Files, c.Content); err != nil {
+			return fmt.Errorf("commit failed: %w", err)
+		}
+	}
+
+	after, err := git.Head()
+	if err != nil {
+		return err
+	}
+	if err := plan.SaveLastRun(plan.LastRun{Before: before, After: after, Commits: len(p.Commits)}); err != nil {
+		logg.Error("Could not record run for undo: %v", err)
+	}
+
+	if !s.mode.NoPush {
+		if err := git.PushChanges(logg); err != nil {
+			return exitcode.Wrap(exitcode.PushRejected, fmt.Errorf("push failed; the commits were kept locally: %w", err))
+		}
+	}
+	return nil
+}
+
+// printPlan lists the planned commits in order. With explain it also shows
+// each commit's confidence, the signals behind every file's classification
+// and the rationale.
+func printPlan(s *session, p plan.Plan, explain bool) {
+	s.log.Info("\n--- Commit Plan (%d commit(s)) ---", len(p.Commits))
+	for i, c := range p.Commits {
+		s.log.Info("%d. %s", i+1, strings.SplitN(c.Message, "\n", 2)[0])
+		s.log.Info("   files: %s", strings.Join(c.Files, ", "))
+		if !explain {
+			continue
+		}
+		if c.Confidence > 0 {
+			s.log.Info("   confidence: %.2f", c.Confidence)
+		}
+		for _, signal := range c.Signals {
+			s.log.Info("   signal %s", signal)
+		}
+		for _, note := range c.Rationale {
+			s.log.Info("   # %s", note)
+		}
+	}
+}
+
+// printReport prints the dry-run report: every stage that ran, then the
+// exact git commands a real run would execute. p is nil when the run would
+// have aborted before a plan existed.
+func (s *session) printReport(p *plan.Plan) {
+	s.log.Info("\n--- Dry Run Report ---")
+	for _, stage := range s.stages {
+		s.log.Info("✔ %s", stage)
+	}
+	if p == nil {
+		s.log.Info("A real run would abort here. Dry run: no changes were made.")
+		return
+	}
+
+	for i, c := range p.Commits {
+		s.log.Info("\nCommit %d of %d: %s", i+1, len(p.Commits), strings.SplitN(c.Message, "\n", 2)[0])
+		for _, note := range c.Rationale {
+			s.log.Info("  # %s", note)
+		}
+		blobs, err := git.Blobs(c.Content, false)
+		if err != nil {
+			s.log.Error("%v", err)
+		}
+		for _, args := range git.CommitCommands(c.Message, c.Files, blobs) {
+			s.log.Info("  %s", shellCommand(args))
+		}
+	}
+	if !s.mode.NoPush {
+		s.log.Info("\nThen push:\n  %s", shellCommand(git.PushCommand()))
+	}
+	s.log.Info("\nDry run: no changes were made.")
+}
+
+// shellCommand renders a git invocation as a copy-pasteable shell command.
+func shellCommand(args []string) string {
+	quoted := []string{"git"}
+	for _, arg := range args {
+		if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:@") == "" {
+			quoted = append(quoted, arg)
+			continue
+		}
+		quoted = append(quoted, "'"+strings.ReplaceAll(arg, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
+
+// runUndo resets the branch to where it was before the last run. It refuses
+// when HEAD has moved since, or when the commits were already pushed.
+func runUndo(s *session) error {
+	last, err := plan.LoadLastRun()
+	if err != nil || last.Before == "" {
+		return exitcode.New(exitcode.NoChanges, "Nothing to undo.")
+	}
+
+	head, err := git.Head()
+	if err != nil {
+		return err
+	}
+	if head != last.After {
+		return exitcode.New(exitcode.UnsafeGitState, "HEAD has moved since the last run (%s, expected %s); refusing to undo", head, last.After)
+	}
+	if git.IsAncestor(last.After, "@{u}") {
+		return exitcode.New(exitcode.UnsafeGitState, "the last run's commits were already pushed; refusing to undo")
+	}
+
+	if err := git.ResetTo(s.log, last.Before); err != nil {
+		return fmt.Errorf("undo failed: %w", err)
+	}
+	if err := plan.SaveLastRun(plan.LastRun{}); err != nil {
+		s.log.Error("%v", err)
+	}
+	s.log.Info("Undid %d commit(s); changes are back in the working tree.", last.Commits)
+	return nil
+}
+
+// runLearn rebuilds the learned data from the full commit history.
+func runLearn(s *session) error {
+	data := history.LearnFromHistory(s.log)
+	if err := history.SaveLearnedData(s.log, data); err != nil {
+		return fmt.Errorf("could not save learned data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/stats"
+)
+
+// runStats prints the commit statistics of the current branch. With JSON
+// output, progress messages are dropped so stdout holds only the report.
+func runStats(s *session, asJSON bool, top int) error {
+	logg := s.log
+	if asJSON {
+		logg = logger.NewQuietLogger()
+	}
+
+	learned, err := loadLearnedData(s, logg)
+	if err != nil {
+		return err
+	}
+	rules, err := config.LoadCommitRules(s.cfg)
+	if err != nil {
+		return fmt.Errorf("could not load commit rules: %w", err)
+	}
+	commits, err := git.CommitsWithFiles(logg, "--no-merges")
+	if err != nil {
+		return err
+	}
+
+	report := stats.Build(learned, commits, rules, top)
+	if asJSON {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal stats: %w", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	return report.WriteTable(os.Stdout)
+}
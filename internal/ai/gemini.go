@@ -19,8 +19,9 @@ const (
 	initialBackoff = 2 * time.Second
 )
 
-// GenerateAICommitMessage uses the Gemini API (via HTTP POST) to generate a commit message based on the provided diff.
-func GenerateAICommitMessage(log logger.Logger, diff string) (string, error) {
+// GenerateAICommitMessage uses the Gemini API (via HTTP POST) to generate a commit message from a prompt
+// built by the prompt package.
+func GenerateAICommitMessage(log logger.Logger, prompt string) (string, error) {
 	log.Debug("Generating AI commit message...")
 	apiKey := os.Getenv("GEMINI_API_KEY")
 	if apiKey == "" {
@@ -29,15 +30,6 @@ func GenerateAICommitMessage(log logger.Logger, diff string) (string, error) {
 
 	url := fmt.Sprintf(geminiAPIURL, apiKey)
 
-	prompt := fmt.Sprintf(`Generate a concise conventional commit message (type: subject) for the following Git diff.
-The commit message should accurately summarize the changes.
-Do not include any explanations or additional text, just the commit message.
-
-Example: feat: add new user authentication endpoint
-
-Diff:
-%s`, diff)
-
 	log.Debug("Prompt:\n%s", prompt)
 
 	requestBody, err := json.Marshal(map[string]interface{}{
@@ -0,0 +1,171 @@
+package classify
+
+import (
+	"path"
+	"strings"
+)
+
+// FileChange is a changed file as listed by `git status --porcelain`.
+// Status is the porcelain status code with spaces removed, e.g. "M", "A",
+// "D", "R" or "??".
+type FileChange struct {
+	Path   string
+	Status string
+}
+
+// Added reports whether the file is new, staged or untracked.
+func (f FileChange) Added() bool {
+	return f.Status == "??" || strings.Contains(f.Status, "A")
+}
+
+// Deleted reports whether the file was removed.
+func (f FileChange) Deleted() bool {
+	return strings.Contains(f.Status, "D")
+}
+
+// Renamed reports whether the file was renamed.
+func (f FileChange) Renamed() bool {
+	return strings.Contains(f.Status, "R")
+}
+
+// Analyzer classifies the files of one kind. It reports false when it has
+// no opinion about a file, leaving it to the keyword heuristics.
+type Analyzer interface {
+	Analyze(f FileChange) (Classification, bool)
+}
+
+// analyzers maps an analyzer key, see analyzerKey, to its analyzer.
+var analyzers = map[string]Analyzer{}
+
+// Register makes a the analyzer for files with the given key: an extension
+// such as ".go", or a base name for files that have none, such as
+// "Dockerfile". A later registration for the same key replaces the earlier.
+func Register(key string, a Analyzer) {
+	analyzers[key] = a
+}
+
+func init() {
+	Register(".go", goAnalyzer{})
+	for _, ext := range []string{".md", ".markdown"} {
+		Register(ext, markdownAnalyzer{})
+	}
+	for _, ext := range []string{".yml", ".yaml", ".toml", ".json", ".ini"} {
+		Register(ext, configAnalyzer{})
+	}
+	for _, key := range []string{"Makefile", "makefile", "GNUmakefile", ".mk", ".sh"} {
+		Register(key, buildAnalyzer{})
+	}
+	Register("Dockerfile", dockerAnalyzer{})
+	Register(".dockerignore", dockerAnalyzer{})
+	Register("Jenkinsfile", configAnalyzer{})
+	Register(".sql", migrationAnalyzer{})
+	for _, key := range []string{".mod", ".sum", ".lock", "Gemfile", ".txt"} {
+		Register(key, configAnalyzer{})
+	}
+}
+
+// analyzerKey returns the key a file's analyzer is registered under: the
+// lower-cased extension, or the base name for files without one. Dockerfile
+// variants such as Dockerfile.dev share the "Dockerfile" key.
+func analyzerKey(p string) string {
+	base := path.Base(p)
+	if strings.HasPrefix(base, "Dockerfile") || strings.HasSuffix(base, ".Dockerfile") {
+		return "Dockerfile"
+	}
+	if ext := path.Ext(base); ext != "" && ext != base {
+		return strings.ToLower(ext)
+	}
+	return base
+}
+
+// analyze runs the analyzer registered for f, if any.
+func analyze(f FileChange) (Classification, bool) {
+	a, ok := analyzers[analyzerKey(f.Path)]
+	if !ok {
+		return Classification{}, false
+	}
+	return a.Analyze(f)
+}
+
+// goAnalyzer compares Go declarations with HEAD.
+type goAnalyzer struct{}
+
+func (goAnalyzer) Analyze(f FileChange) (Classification, bool) {
+	return classifyGo(f.Path)
+}
+
+// markdownAnalyzer treats Markdown as documentation.
+type markdownAnalyzer struct{}
+
+func (markdownAnalyzer) Analyze(f FileChange) (Classification, bool) {
+	return Classification{Type: "docs", Reason: "Markdown file"}, true
+}
+
+// dockerAnalyzer treats container build files as build changes.
+type dockerAnalyzer struct{}
+
+func (dockerAnalyzer) Analyze(f FileChange) (Classification, bool) {
+	return Classification{Type: "build", Reason: "container build file"}, true
+}
+
+// buildAnalyzer treats Makefiles and build scripts as build changes. Shell
+// scripts other than build*.sh are left to the keyword heuristics.
+type buildAnalyzer struct{}
+
+func (buildAnalyzer) Analyze(f FileChange) (Classification, bool) {
+	base := path.Base(f.Path)
+	if path.Ext(base) == ".sh" && !strings.HasPrefix(base, "build") {
+		return Classification{}, false
+	}
+	return Classification{Type: "build", Reason: "build script " + base}, true
+}
+
+// manifests are package manifests and lockfiles, by base name.
+var manifests = map[string]bool{
+	"go.mod": true, "go.sum": true, "go.work": true, "go.work.sum": true,
+	"package.json": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true,
+	"Cargo.toml": true, "Cargo.lock": true, "pyproject.toml": true, "poetry.lock": true,
+	"Pipfile.lock": true, "requirements.txt": true, "requirements-dev.txt": true,
+	"Gemfile": true, "Gemfile.lock": true, "composer.json": true, "composer.lock": true,
+}
+
+// ciFiles are CI configuration files outside .github/workflows, by path.
+var ciFiles = map[string]bool{
+	".gitlab-ci.yml": true, ".travis.yml": true, "azure-pipelines.yml": true,
+	".circleci/config.yml": true, "Jenkinsfile": true, "bitbucket-pipelines.yml": true,
+}
+
+// configAnalyzer handles YAML, TOML, JSON and similar files: CI workflows
+// are ci, package manifests are build, and other configuration is chore.
+// Files it does not recognise, such as arbitrary .txt or .lock files, are
+// left to the keyword heuristics.
+type configAnalyzer struct{}
+
+func (configAnalyzer) Analyze(f FileChange) (Classification, bool) {
+	base := path.Base(f.Path)
+	switch {
+	case strings.HasPrefix(f.Path, ".github/workflows/") || ciFiles[f.Path]:
+		return Classification{Type: "ci", Reason: "CI workflow file"}, true
+	case manifests[base]:
+		return Classification{Type: "build", Reason: "package manifest " + base}, true
+	}
+	switch analyzerKey(f.Path) {
+	case ".yml", ".yaml", ".toml", ".json", ".ini":
+		return Classification{Type: "chore", Reason: "configuration file", Confidence: 0.6}, true
+	}
+	return Classification{}, false
+}
+
+// migrationAnalyzer classifies SQL files under a migrations directory: a new
+// migration is a feature, an edited one a fix.
+type migrationAnalyzer struct{}
+
+func (migrationAnalyzer) Analyze(f FileChange) (Classification, bool) {
+	if !strings.Contains(strings.ToLower(f.Path), "migration") {
+		return Classification{}, false
+	}
+	if f.Added() {
+		return Classification{Type: "feat", Reason: "new database migration"}, true
+	}
+	return Classification{Type: "fix", Reason: "changed database migration", Confidence: 0.6}, true
+}
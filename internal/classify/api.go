@@ -0,0 +1,281 @@
+package classify
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+)
+
+// APIChange is a change to the exported API of a Go package that breaks its
+// importers. File is where the affected identifier is declared: at HEAD for
+// removals, in the working tree otherwise.
+type APIChange struct {
+	File        string
+	Ident       string
+	Description string
+}
+
+// apiEntry is one element of a package's exported API surface: a func,
+// method, type, var, const, struct field or interface method.
+type apiEntry struct {
+	file      string
+	kind      string
+	signature string
+	// parent is the type a field or method belongs to.
+	parent string
+}
+
+// CompareAPI compares the exported API of every Go package with a changed
+// file between HEAD and the working tree. It reports removed identifiers,
+// renamed funcs, changed signatures, removed struct fields and methods added
+// to interfaces. Test files, main packages and internal packages are not
+// part of any API.
+func CompareAPI(files []string) []APIChange {
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		if isAPIFile(f) && !isInternal(path.Dir(f)) {
+			dirs[path.Dir(f)] = true
+		}
+	}
+
+	var changes []APIChange
+	for dir := range dirs {
+		pkg, before := headAPI(dir)
+		p, after := worktreeAPI(dir)
+		if p != "" {
+			pkg = p
+		}
+		changes = append(changes, diffAPI(pkg, before, after)...)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Description < changes[j].Description })
+	return changes
+}
+
+// isInternal reports whether dir is an internal package, which only its
+// own module can import.
+func isInternal(dir string) bool {
+	for _, elem := range strings.Split(dir, "/") {
+		if elem == "internal" {
+			return true
+		}
+	}
+	return false
+}
+
+func isAPIFile(f string) bool {
+	return strings.HasSuffix(f, ".go") && !strings.HasSuffix(f, "_test.go")
+}
+
+// headAPI returns the package name and exported API of dir at HEAD.
+func headAPI(dir string) (string, map[string]apiEntry) {
+	names, err := git.HeadFiles(dir)
+	if err != nil {
+		return "", nil
+	}
+	sources := make(map[string][]byte)
+	for _, name := range names {
+		if isAPIFile(name) {
+			if src, err := git.ShowHead(name); err == nil {
+				sources[name] = src
+			}
+		}
+	}
+	return exportedAPI(sources)
+}
+
+// worktreeAPI returns the package name and exported API of dir as it is in
+// the working tree.
+func worktreeAPI(dir string) (string, map[string]apiEntry) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", nil
+	}
+	sources := make(map[string][]byte)
+	for _, e := range entries {
+		name := path.Join(dir, e.Name())
+		if e.IsDir() || !isAPIFile(name) {
+			continue
+		}
+		if src, err := ioutil.ReadFile(filepath.FromSlash(name)); err == nil {
+			sources[name] = src
+		}
+	}
+	return exportedAPI(sources)
+}
+
+// exportedAPI parses the sources of one package and indexes its exported
+// API by qualified identifier, e.g. "Config.Name" for a struct field. Main
+// packages have no API.
+func exportedAPI(sources map[string][]byte) (string, map[string]apiEntry) {
+	pkg := ""
+	api := make(map[string]apiEntry)
+	for name, src := range sources {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, name, src, 0)
+		if err != nil || file.Name.Name == "main" {
+			continue
+		}
+		pkg = file.Name.Name
+		add := func(ident, kind, signature, parent string) {
+			api[ident] = apiEntry{file: name, kind: kind, signature: signature, parent: parent}
+		}
+
+		for _, d := range file.Decls {
+			switch d := d.(type) {
+			case *ast.FuncDecl:
+				if !d.Name.IsExported() {
+					continue
+				}
+				if d.Recv == nil || len(d.Recv.List) == 0 {
+					add(d.Name.Name, "func", render(fset, d.Type), "")
+					continue
+				}
+				recv := receiverName(d.Recv.List[0].Type)
+				if ast.IsExported(recv) {
+					add(recv+"."+d.Name.Name, "method", "("+render(fset, d.Recv.List[0].Type)+") "+render(fset, d.Type), recv)
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					switch s := spec.(type) {
+					case *ast.TypeSpec:
+						if !s.Name.IsExported() {
+							continue
+						}
+						addType(fset, s, add)
+					case *ast.ValueSpec:
+						for _, n := range s.Names {
+							if n.IsExported() {
+								sig := d.Tok.String()
+								if s.Type != nil {
+									sig += " " + render(fset, s.Type)
+								}
+								add(n.Name, d.Tok.String(), sig, "")
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	return pkg, api
+}
+
+// addType indexes an exported type and, for structs and interfaces, its
+// exported fields and methods.
+func addType(fset *token.FileSet, s *ast.TypeSpec, add func(ident, kind, signature, parent string)) {
+	typeName := s.Name.Name
+	params := ""
+	if s.TypeParams != nil {
+		params = render(fset, s.TypeParams)
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		add(typeName, "type", "type "+params+" struct", "")
+		for _, f := range t.Fields.List {
+			names := f.Names
+			if len(names) == 0 {
+				// An embedded field is named after its type.
+				names = []*ast.Ident{ast.NewIdent(receiverName(f.Type))}
+			}
+			for _, n := range names {
+				if n.IsExported() {
+					add(typeName+"."+n.Name, "field", render(fset, f.Type), typeName)
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		add(typeName, "type", "type "+params+" interface", "")
+		for _, m := range t.Methods.List {
+			if len(m.Names) == 0 {
+				add(typeName+"."+render(fset, m.Type), "method", "embedded", typeName)
+				continue
+			}
+			for _, n := range m.Names {
+				add(typeName+"."+n.Name, "method", render(fset, m.Type), typeName)
+			}
+		}
+	default:
+		add(typeName, "type", "type "+params+" "+render(fset, s.Type), "")
+	}
+}
+
+// diffAPI lists the breaking differences between two API surfaces of pkg.
+func diffAPI(pkg string, before, after map[string]apiEntry) []APIChange {
+	var changes []APIChange
+	report := func(file, ident, format string, args ...interface{}) {
+		changes = append(changes, APIChange{File: file, Ident: pkg + "." + ident, Description: fmt.Sprintf(format, args...)})
+	}
+
+	renamed := make(map[string]bool)
+	for _, ident := range sortedIdents(before) {
+		b := before[ident]
+		a, ok := after[ident]
+		switch {
+		case ok && a.signature != b.signature:
+			report(a.file, ident, "changed the signature of %s.%s", pkg, ident)
+		case ok:
+		case b.parent != "":
+			if _, parentKept := after[b.parent]; parentKept {
+				report(b.file, ident, "removed %s %s.%s", b.kind, pkg, ident)
+			}
+		default:
+			if to := renamedTo(ident, b, before, after, renamed); to != "" {
+				report(b.file, ident, "renamed %s.%s to %s.%s", pkg, ident, pkg, to)
+			} else {
+				report(b.file, ident, "removed %s.%s", pkg, ident)
+			}
+		}
+	}
+	for ident, a := range after {
+		if _, ok := before[ident]; ok || a.kind != "method" {
+			continue
+		}
+		if parent, ok := before[a.parent]; ok && strings.HasSuffix(parent.signature, "interface") {
+			report(a.file, ident, "added method %s to interface %s.%s", strings.TrimPrefix(ident, a.parent+"."), pkg, a.parent)
+		}
+	}
+	return changes
+}
+
+// renamedTo returns the new name of a removed func when exactly one func
+// with the same signature was added in the same file and not yet claimed
+// by another rename.
+func renamedTo(ident string, b apiEntry, before, after map[string]apiEntry, taken map[string]bool) string {
+	if b.kind != "func" {
+		return ""
+	}
+	match := ""
+	for _, candidate := range sortedIdents(after) {
+		a := after[candidate]
+		if _, existed := before[candidate]; existed || taken[candidate] || a.kind != "func" || a.signature != b.signature || a.file != b.file {
+			continue
+		}
+		if match != "" {
+			return ""
+		}
+		match = candidate
+	}
+	if match != "" {
+		taken[match] = true
+	}
+	return match
+}
+
+// sortedIdents returns the identifiers of api in order, so renames are
+// matched the same way on every run.
+func sortedIdents(api map[string]apiEntry) []string {
+	idents := make([]string, 0, len(api))
+	for ident := range api {
+		idents = append(idents, ident)
+	}
+	sort.Strings(idents)
+	return idents
+}
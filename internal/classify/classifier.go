@@ -2,71 +2,319 @@ package classify
 
 import (
 	"fmt"
-	"os/exec"
+	"path"
+	"sort"
 	"strings"
 
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
 	"github.com/urstruelysv/autocommit-cli/internal/history"
 	"github.com/urstruelysv/autocommit-cli/internal/logger"
 )
 
-func ClassifyAndGroupChanges(log logger.Logger, changes string, learnedData history.LearnData) map[string][]string {
+// Classification is the commit type chosen for a single file and why.
+// Scope, when set by a configured rule, overrides the predicted scope.
+// Confidence, from 0 to 1, says how sure the classification is, and Signals
+// names the kinds of evidence behind it, such as "keyword" or "AST".
+type Classification struct {
+	Type       string
+	Scope      string
+	Reason     string
+	Confidence float64
+	Signals    []string
+
+	// derived is the kind of generated, vendored or lockfile, if any.
+	derived string
+}
+
+// Group is a set of files that go into the same commit. Reasons holds one
+// "path: reason" line per file. Breaking lists the changes to exported Go
+// API declared in the group's files. Content holds the version to commit
+// for files the group only takes part of a change from. Confidence is the
+// lowest confidence of the group's files, and Signals holds one
+// "path: type confidence (signals)" line per file.
+type Group struct {
+	Type       string
+	Scope      string
+	Files      []string
+	Reasons    []string
+	Breaking   []string
+	Content    map[string]string
+	Confidence float64
+	Signals    []string
+}
+
+// Key returns the conventional commit prefix of the group, e.g. "feat(api)".
+func (g Group) Key() string {
+	if g.Scope != "" {
+		return fmt.Sprintf("%s(%s)", g.Type, g.Scope)
+	}
+	return g.Type
+}
+
+// ClassifyAndGroupChanges classifies every changed file and groups the files
+// by type and scope. Configured rules are tried before the built-in
+// heuristics. Groups are returned sorted by key.
+func ClassifyAndGroupChanges(log logger.Logger, changes string, learnedData history.LearnData, rules []config.ClassifyRule) []Group {
 	log.Debug("Classifying and grouping changes...")
 	log.Info("\n--- Classifying and Grouping Changes ---")
-	groups := make(map[string][]string)
+	groups := make(map[string]*Group)
+	var files []string
+	var fileChanges []FileChange
+	compiled := compileRules(rules)
 
-	lines := strings.Split(changes, "\n")
-	for _, line := range lines {
+	var untracked []string
+	for _, line := range strings.Split(changes, "\n") {
 		parts := strings.Fields(line)
 		if len(parts) < 2 {
 			continue
 		}
-		filePath := parts[len(parts)-1]
-		commitType := "chore" // Default type
+		change := FileChange{Path: parts[len(parts)-1], Status: parts[0]}
+		if change.Status == "??" {
+			untracked = append(untracked, change.Path)
+		}
+		files = append(files, change.Path)
+		fileChanges = append(fileChanges, change)
+	}
+
+	// One diff for the whole snapshot, split into per-file patches.
+	patches, err := git.SnapshotDiff(log, untracked)
+	if err != nil {
+		log.Error("Could not read the diff; classifying without it: %v", err)
+		patches = git.Patches{}
+	}
+
+	reverts := findReverts(log, fileChanges)
+
+	derived := make(map[string]string)
+	for _, change := range fileChanges {
+		filePath := change.Path
 		scope := ""
 
-		pathParts := strings.Split(filePath, "/")
-		// Determine scope from file path
-		for _, part := range pathParts {
-			if _, ok := learnedData.Scopes[part]; ok {
-				scope = part
-				break
+		// Predict the scope from the scopes this path was committed under
+		// before, falling back to a path segment named like a learned scope.
+		if predicted, share := learnedData.Paths.PredictScope(filePath); predicted != "" {
+			log.Debug("Scope '%s' predicted for %s from history (%.0f%%)", predicted, filePath, share*100)
+			scope = predicted
+		} else {
+			pathParts := strings.Split(filePath, "/")
+			for _, part := range pathParts {
+				if _, ok := learnedData.Scopes[part]; ok {
+					scope = part
+					break
+				}
 			}
 		}
 
-		if strings.Contains(filePath, "tests/") || strings.HasPrefix(filePath, "test_") {
-			commitType = "test"
-		} else if strings.HasSuffix(filePath, ".md") {
-			commitType = "docs"
+		c := classifyFile(change, patches.For(filePath), reverts, learnedData, compiled)
+		log.Debug("%s: %s (%s)", filePath, c.Type, c.Reason)
+		if c.Scope != "" {
+			scope = c.Scope
+		}
+		if c.derived != "" {
+			derived[filePath] = c.derived
+		}
+
+		g := &Group{Type: c.Type, Scope: scope}
+		if existing, ok := groups[g.Key()]; ok {
+			g = existing
 		} else {
-			diffCmd := exec.Command("git", "diff", "--", filePath)
-			diffOutput, err := diffCmd.Output()
-			if err != nil {
-				log.Error("Could not get diff for %s: %v", filePath, err)
-			} else {
-				diff := strings.ToLower(string(diffOutput))
-				if strings.Contains(diff, "fix") || strings.Contains(diff, "bug") || strings.Contains(diff, "error") {
-					commitType = "fix"
-				} else if strings.Contains(diff, "feat") || strings.Contains(diff, "add") || strings.Contains(diff, "feature") || strings.Contains(diff, "implement") {
-					commitType = "feat"
-				} else if strings.Contains(diff, "refactor") || strings.Contains(diff, "restructure") || strings.Contains(diff, "rename") {
-					commitType = "refactor"
-				} else if strings.Contains(diff, "chore") || strings.Contains(diff, "update") || strings.Contains(diff, "remove") || strings.Contains(diff, "config") {
-					commitType = "chore"
-				}
+			groups[g.Key()] = g
+		}
+		if len(g.Files) == 0 || c.Confidence < g.Confidence {
+			g.Confidence = c.Confidence
+		}
+		g.Files = append(g.Files, filePath)
+		g.Reasons = append(g.Reasons, fmt.Sprintf("%s: %s", filePath, c.Reason))
+		g.Signals = append(g.Signals, fmt.Sprintf("%s: %s %.2f (%s)", filePath, c.Type, c.Confidence, strings.Join(c.Signals, ", ")))
+	}
+
+	attachDerived(log, groups, derived)
+	groupByImports(log, groups)
+
+	// Each breaking API change goes with the group holding its file, or
+	// failing that (a renamed file) a group in the same package.
+	for _, change := range CompareAPI(files) {
+		if g := groupFor(groups, change.File); g != nil {
+			g.Breaking = append(g.Breaking, change.Description)
+			log.Debug("Breaking change in group '%s': %s", g.Key(), change.Description)
+		}
+	}
+
+	splitFormattingOff(log, groups, fileChanges, patches, derived)
+
+	// Groups that commit part of a file go before the rest of its change.
+	result := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if partI, partJ := len(result[i].Content) > 0, len(result[j].Content) > 0; partI != partJ {
+			return partI
+		}
+		return result[i].Key() < result[j].Key()
+	})
+
+	for _, g := range result {
+		log.Debug("Group '%s': %v", g.Key(), g.Files)
+		log.Info("Group '%s': %v", g.Key(), g.Files)
+	}
+
+	return result
+}
+
+// splitFormattingOff moves the formatting mixed into each hand-written
+// file's change into a style group, leaving the rest of the change with the
+// file's group.
+func splitFormattingOff(log logger.Logger, groups map[string]*Group, changes []FileChange, patches git.Patches, derived map[string]string) {
+	for _, change := range changes {
+		owner := groupFor(groups, change.Path)
+		if _, ok := derived[change.Path]; ok || owner == nil || owner.Type == "style" {
+			continue
+		}
+		content, what, ok := splitFormatting(change, patches.For(change.Path))
+		if !ok {
+			continue
+		}
+		g := &Group{Type: "style", Scope: owner.Scope}
+		if existing, ok := groups[g.Key()]; ok {
+			g = existing
+		} else {
+			groups[g.Key()] = g
+		}
+		if g.Content == nil {
+			g.Content = make(map[string]string)
+		}
+		if len(g.Files) == 0 || g.Confidence > 0.9 {
+			g.Confidence = 0.9
+		}
+		g.Signals = append(g.Signals, fmt.Sprintf("%s: style 0.90 (formatting)", change.Path))
+		g.Files = append(g.Files, change.Path)
+		g.Content[change.Path] = content
+		g.Reasons = append(g.Reasons, fmt.Sprintf("%s: %s split off from the %s change", change.Path, what, owner.Key()))
+		owner.Reasons = append(owner.Reasons, fmt.Sprintf("%s: %s committed separately as %s", change.Path, what, g.Key()))
+		log.Debug("Split %s out of %s into '%s'", what, change.Path, g.Key())
+	}
+}
+
+// groupFor returns the group containing file, or else one with a file in
+// the same directory, or else the first group by key.
+func groupFor(groups map[string]*Group, file string) *Group {
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if contains(groups[k].Files, file) {
+			return groups[k]
+		}
+	}
+	for _, k := range keys {
+		for _, f := range groups[k].Files {
+			if path.Dir(f) == path.Dir(file) {
+				return groups[k]
 			}
 		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return groups[keys[0]]
+}
+
+// classifyFile picks the commit type for one file from its patch:
+// configured rules first, then generated, vendored and lockfiles, reverts,
+// formatting-only changes, test paths, the analyzer registered for the
+// file type, and keywords in the diff.
+func classifyFile(change FileChange, patch string, reverts map[string]Classification, learnedData history.LearnData, rules []compiledRule) Classification {
+	filePath := change.Path
+	for _, r := range rules {
+		if r.match(change, patch) {
+			return signal(r.classification(), "config rule", 1)
+		}
+	}
+	if kind := DerivedKind(filePath); kind != "" {
+		return derivedClassification(kind)
+	}
+	if c, ok := reverts[filePath]; ok {
+		return signal(c, "revert", 0.95)
+	}
+	if c, ok := classifyFormatting(change, patch); ok {
+		return signal(c, "formatting", 0.9)
+	}
+	if strings.Contains(filePath, "tests/") || strings.HasPrefix(filePath, "test_") || strings.HasSuffix(filePath, "_test.go") {
+		return signal(Classification{Type: "test", Reason: "test file"}, "test path", 0.9)
+	}
+	if c, ok := analyze(change); ok {
+		return signal(c, "file type", 0.8)
+	}
 
-		groupKey := commitType
-		if scope != "" {
-			groupKey = fmt.Sprintf("%s(%s)", commitType, scope)
+	c := Classification{Type: "chore", Reason: "no keyword in the diff", Confidence: 0.2, Signals: []string{"fallback"}}
+	keyword := false
+	lower := strings.ToLower(patch)
+	for _, k := range keywords {
+		if word := containsAny(lower, k.words); word != "" {
+			c = signal(Classification{Type: k.commitType, Reason: fmt.Sprintf("diff mentions %q", word)}, "keyword", 0.4)
+			keyword = true
+			break
 		}
-		groups[groupKey] = append(groups[groupKey], filePath)
 	}
+	predicted, share := learnedData.Paths.PredictType(filePath)
+	switch {
+	case predicted == "":
+	case keyword && predicted == c.Type:
+		// History agreeing with the keyword makes both more credible.
+		c.Reason += fmt.Sprintf(", like %.0f%% of past commits to this path", share*100)
+		c.Confidence = 1 - (1-c.Confidence)*(1-share*0.8)
+		c.Signals = append(c.Signals, "history")
+	case c.Type == "chore":
+		// Keywords rarely tell chores apart; prefer the type this path
+		// is usually committed under.
+		c = signal(Classification{Type: predicted, Reason: fmt.Sprintf("%.0f%% of past commits to this path were %s", share*100, predicted)}, "history", share*0.8)
+	}
+	return c
+}
+
+// signal records the kind of evidence behind c, and its confidence unless
+// the analyzer that produced c already set them.
+func signal(c Classification, name string, confidence float64) Classification {
+	if len(c.Signals) == 0 {
+		c.Signals = []string{name}
+	}
+	if c.Confidence == 0 {
+		c.Confidence = confidence
+	}
+	return c
+}
+
+// keywords map words in a diff to commit types, checked in order.
+var keywords = []struct {
+	commitType string
+	words      []string
+}{
+	{"perf", []string{"performance", "optimiz", "faster", "speed up"}},
+	{"fix", []string{"fix", "bug", "error"}},
+	{"feat", []string{"feat", "add", "feature", "implement"}},
+	{"refactor", []string{"refactor", "restructure", "rename"}},
+	{"chore", []string{"chore", "update", "remove", "config"}},
+}
 
-	for groupKey, files := range groups {
-		log.Debug("Group '%s': %v", groupKey, files)
-		log.Info("Group '%s': %v", groupKey, files)
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
 	}
+	return false
+}
 
-	return groups
+// containsAny returns the first of words found in s, or "".
+func containsAny(s string, words []string) string {
+	for _, w := range words {
+		if strings.Contains(s, w) {
+			return w
+		}
+	}
+	return ""
 }
@@ -0,0 +1,316 @@
+package classify
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+)
+
+// freeFormExts are the extensions of languages in which whitespace between
+// tokens on a line carries no meaning. Elsewhere, as in Python, YAML or
+// Makefiles, a whitespace change can change what the file does, and in
+// JavaScript a line break can end a statement.
+var freeFormExts = map[string]bool{
+	".go": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true,
+	".cs": true, ".java": true, ".kt": true, ".scala": true, ".swift": true, ".rs": true,
+	".php": true, ".css": true, ".scss": true, ".less": true, ".json": true, ".sql": true,
+	".proto": true,
+}
+
+// classifyFormatting reports a modified file whose changes are only
+// whitespace within lines and blank lines or, for Go, only gofmt output
+// and import reordering.
+func classifyFormatting(change FileChange, patch string) (Classification, bool) {
+	if change.Added() || change.Deleted() || change.Renamed() {
+		return Classification{}, false
+	}
+	if !strings.HasSuffix(change.Path, ".go") {
+		hunks, _ := parseHunks(patch)
+		if len(hunks) == 0 {
+			return Classification{}, false
+		}
+		whitespace := true
+		for _, h := range hunks {
+			whitespace = whitespace && whitespaceOnly(change.Path, h.Old, h.New)
+		}
+		if whitespace {
+			return Classification{Type: "style", Reason: "whitespace-only changes"}, true
+		}
+		return Classification{}, false
+	}
+	head, current, ok := versions(change.Path)
+	if !ok {
+		return Classification{}, false
+	}
+	before, errBefore := format.Source(head)
+	after, errAfter := format.Source(current)
+	if errBefore != nil || errAfter != nil {
+		return Classification{}, false
+	}
+	if bytes.Equal(before, after) {
+		return Classification{Type: "style", Reason: "gofmt-only changes"}, true
+	}
+	if moved, ok := withImportsOf(before, after); ok && bytes.Equal(moved, after) {
+		return Classification{Type: "style", Reason: "import reordering"}, true
+	}
+	return Classification{}, false
+}
+
+// splitFormatting finds the formatting mixed into a modified file's change.
+// It returns the file's HEAD version with only that formatting applied, to
+// be committed before the rest of the change, and what the formatting was.
+func splitFormatting(change FileChange, patch string) (string, string, bool) {
+	if change.Added() || change.Deleted() || change.Renamed() {
+		return "", "", false
+	}
+	head, current, ok := versions(change.Path)
+	if !ok {
+		return "", "", false
+	}
+
+	if strings.HasSuffix(change.Path, ".go") {
+		base := head
+		var what []string
+		// gofmt output is only part of the change when the new version is
+		// itself gofmt-clean.
+		if formatted, err := format.Source(head); err == nil && !bytes.Equal(formatted, head) {
+			if clean, err := format.Source(current); err == nil && bytes.Equal(clean, current) {
+				base = formatted
+				what = append(what, "gofmt output")
+			}
+		}
+		if moved, ok := withImportsOf(base, current); ok && !bytes.Equal(moved, base) {
+			base = moved
+			what = append(what, "import reordering")
+		}
+		if len(what) > 0 && !bytes.Equal(base, current) {
+			return string(base), strings.Join(what, " and "), true
+		}
+	}
+
+	base, n := applyWhitespaceHunks(change.Path, patch, head)
+	if n == 0 || bytes.Equal(base, current) {
+		return "", "", false
+	}
+	// For Go, gofmt has the last word: the hunks must not have changed
+	// anything it keeps, such as a raw string spanning lines.
+	if strings.HasSuffix(change.Path, ".go") {
+		before, errBefore := format.Source(head)
+		after, errAfter := format.Source(base)
+		if errBefore != nil || errAfter != nil || !bytes.Equal(before, after) {
+			return "", "", false
+		}
+	}
+	return string(base), fmt.Sprintf("%d whitespace-only hunk(s)", n), true
+}
+
+// versions returns a file's content at HEAD and in the working tree.
+func versions(path string) ([]byte, []byte, bool) {
+	head, err := git.ShowHead(path)
+	if err != nil {
+		return nil, nil, false
+	}
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, false
+	}
+	return head, current, true
+}
+
+// withImportsOf replaces the import declarations of src with those of
+// other, provided both import the same packages.
+func withImportsOf(src, other []byte) ([]byte, bool) {
+	specs, start, end, ok := goImports(src)
+	otherSpecs, otherStart, otherEnd, otherOK := goImports(other)
+	if !ok || !otherOK || start < 0 || otherStart < 0 || strings.Join(specs, "\n") != strings.Join(otherSpecs, "\n") {
+		return nil, false
+	}
+	var b bytes.Buffer
+	b.Write(src[:start])
+	b.Write(other[otherStart:otherEnd])
+	b.Write(src[end:])
+	return b.Bytes(), true
+}
+
+// goImports returns the sorted import specs of a Go file and the byte range
+// its import declarations span, or -1 when it has none.
+func goImports(src []byte) ([]string, int, int, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly|parser.ParseComments)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	start, end := -1, -1
+	for _, d := range f.Decls {
+		if start < 0 {
+			start = fset.Position(d.Pos()).Offset
+		}
+		end = fset.Position(d.End()).Offset
+	}
+	var specs []string
+	for _, spec := range f.Imports {
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		specs = append(specs, name+" "+spec.Path.Value)
+	}
+	sort.Strings(specs)
+	return specs, start, end, true
+}
+
+// hunkHeader matches a unified diff hunk header.
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+\d+(?:,\d+)? @@`)
+
+// hunk is a zero-context diff hunk: Count lines replaced from line Start
+// of the old version.
+type hunk struct {
+	Start, Count int
+	Old, New     []string
+}
+
+// parseHunks parses the hunks of a zero-context patch. exact is false when
+// the patch has lines it cannot represent, such as a missing newline at
+// the end of the file.
+func parseHunks(patch string) (hunks []hunk, exact bool) {
+	exact = true
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, `\`):
+			exact = false
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return hunks, false
+			}
+			h := hunk{Count: 1}
+			h.Start, _ = strconv.Atoi(m[1])
+			if m[2] != "" {
+				h.Count, _ = strconv.Atoi(m[2])
+			}
+			hunks = append(hunks, h)
+		case len(hunks) == 0:
+			// File header lines.
+		case strings.HasPrefix(line, "-"):
+			hunks[len(hunks)-1].Old = append(hunks[len(hunks)-1].Old, line[1:])
+		case strings.HasPrefix(line, "+"):
+			hunks[len(hunks)-1].New = append(hunks[len(hunks)-1].New, line[1:])
+		}
+	}
+	return hunks, exact
+}
+
+// applyWhitespaceHunks applies to head the hunks of the file's patch that
+// only change whitespace, returning the result and how many there were.
+func applyWhitespaceHunks(file, patch string, head []byte) ([]byte, int) {
+	hunks, exact := parseHunks(patch)
+	if !exact {
+		return nil, 0
+	}
+
+	lines := strings.SplitAfter(string(head), "\n")
+	n := 0
+	// Apply from the bottom so earlier line numbers stay valid.
+	for i := len(hunks) - 1; i >= 0; i-- {
+		h := hunks[i]
+		if !whitespaceOnly(file, h.Old, h.New) {
+			continue
+		}
+		from := h.Start - 1
+		if h.Count == 0 {
+			from = h.Start
+		}
+		if from < 0 || from+h.Count > len(lines) {
+			return nil, 0
+		}
+		var replaced []string
+		for _, l := range h.New {
+			replaced = append(replaced, l+"\n")
+		}
+		lines = append(lines[:from], append(replaced, lines[from+h.Count:]...)...)
+		n++
+	}
+	return []byte(strings.Join(lines, "")), n
+}
+
+// whitespaceOnly reports whether two runs of lines of file differ only in
+// whitespace within lines and in blank lines, in a language where that
+// whitespace carries no meaning. String literals and comments must match
+// exactly and lines may not be split or joined.
+func whitespaceOnly(file string, a, b []string) bool {
+	if !freeFormExts[strings.ToLower(path.Ext(file))] {
+		return false
+	}
+	return strings.Join(tokens(strings.Join(a, "\n")), "\x00") == strings.Join(tokens(strings.Join(b, "\n")), "\x00")
+}
+
+// tokens splits source code into words, quoted literals, line comments,
+// line breaks and single punctuation characters, dropping the other
+// whitespace. Runs of line breaks count as one, so blank lines don't
+// matter.
+func tokens(src string) []string {
+	var out []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			if len(out) > 0 && out[len(out)-1] != "\n" {
+				out = append(out, "\n")
+			}
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			j := strings.IndexByte(src[i:], '\n')
+			if j < 0 {
+				j = len(src) - i
+			}
+			out = append(out, strings.TrimRight(src[i:i+j], " \t\r"))
+			i += j
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' && c != '`' {
+					j++
+				}
+				j++
+			}
+			if j < len(src) {
+				j++
+			} else {
+				j = len(src)
+			}
+			out = append(out, src[i:j])
+			i = j
+		case isWordByte(c):
+			j := i
+			for j < len(src) && isWordByte(src[j]) {
+				j++
+			}
+			out = append(out, src[i:j])
+			i = j
+		default:
+			out = append(out, src[i:i+1])
+			i++
+		}
+	}
+	if len(out) > 0 && out[len(out)-1] == "\n" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
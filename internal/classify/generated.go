@@ -0,0 +1,213 @@
+package classify
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+// Kinds of files that are not written by hand. Their diffs say nothing
+// about intent, so they are kept out of keyword classification and AI
+// prompts, and committed with the change to their source.
+const (
+	Generated = "generated"
+	Vendored  = "vendored"
+	Lockfile  = "lockfile"
+)
+
+// lockfileSources maps lockfiles, by base name, to the manifests they are
+// generated from.
+var lockfileSources = map[string][]string{
+	"go.sum":              {"go.mod"},
+	"go.work.sum":         {"go.work"},
+	"package-lock.json":   {"package.json"},
+	"npm-shrinkwrap.json": {"package.json"},
+	"yarn.lock":           {"package.json"},
+	"pnpm-lock.yaml":      {"package.json"},
+	"Cargo.lock":          {"Cargo.toml"},
+	"poetry.lock":         {"pyproject.toml"},
+	"Pipfile.lock":        {"Pipfile"},
+	"Gemfile.lock":        {"Gemfile"},
+	"composer.lock":       {"composer.json"},
+}
+
+// vendorDirs maps vendored dependency trees to the manifests next to them
+// that they are installed from.
+var vendorDirs = map[string][]string{
+	"vendor":       {"go.mod", "composer.json"},
+	"node_modules": {"package.json"},
+}
+
+// generatedHeader is the comment that marks generated Go code, see
+// https://golang.org/s/generatedcode.
+var generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// DerivedKind reports whether path is generated, vendored or a lockfile,
+// returning its kind, or "" for a file written by hand.
+func DerivedKind(p string) string {
+	if lockfileSources[path.Base(p)] != nil {
+		return Lockfile
+	}
+	if vendorRoot(p) != "" {
+		return Vendored
+	}
+	if strings.HasSuffix(p, ".pb.go") || hasGeneratedHeader(p) {
+		return Generated
+	}
+	return ""
+}
+
+// SeparateDerived splits files into those written by hand and those that
+// are generated, vendored or lockfiles.
+func SeparateDerived(files []string) (handwritten, derived []string) {
+	for _, f := range files {
+		if DerivedKind(f) != "" {
+			derived = append(derived, f)
+		} else {
+			handwritten = append(handwritten, f)
+		}
+	}
+	return handwritten, derived
+}
+
+// vendorRoot returns the directory holding the vendored tree p is in, with
+// a trailing slash, or "" when p is not vendored.
+func vendorRoot(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts[:len(parts)-1] {
+		if vendorDirs[part] != nil {
+			return strings.Join(parts[:i+1], "/") + "/"
+		}
+	}
+	if vendorDirs[strings.TrimSuffix(p, "/")] != nil {
+		return p
+	}
+	return ""
+}
+
+// hasGeneratedHeader reports whether a Go file has the generated code
+// comment before its package clause. Deleted files are read from HEAD.
+func hasGeneratedHeader(p string) bool {
+	if !strings.HasSuffix(p, ".go") {
+		return false
+	}
+	content, err := ioutil.ReadFile(p)
+	if err != nil {
+		if content, err = git.ShowHead(p); err != nil {
+			return false
+		}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if generatedHeader.MatchString(line) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+	}
+	return false
+}
+
+// derivedClassification classifies a generated, vendored or lockfile
+// change as a dependency build change. attachDerived later moves it to the
+// commit that changed its source, if there is one.
+func derivedClassification(kind string) Classification {
+	reason := map[string]string{Generated: "generated code", Vendored: "vendored dependency", Lockfile: "dependency lockfile"}[kind]
+	return Classification{Type: "build", Scope: "deps", Reason: reason, Confidence: 0.9, Signals: []string{kind}, derived: kind}
+}
+
+// attachDerived moves each derived file into the group of a changed file it
+// is generated from: a lockfile's manifest, the manifest next to a vendored
+// tree, a .pb.go file's .proto, or a hand-written file in the same
+// directory as generated code. Files with no changed source stay in their
+// build(deps) group.
+func attachDerived(log logger.Logger, groups map[string]*Group, derived map[string]string) {
+	paths := make([]string, 0, len(derived))
+	for p := range derived {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	deps := Group{Type: "build", Scope: "deps"}
+	for _, p := range paths {
+		from := groups[deps.Key()]
+		if from == nil || !contains(from.Files, p) {
+			continue
+		}
+		source, to := sourceOf(groups, derived, p, derived[p])
+		if to == nil || to == from {
+			continue
+		}
+		i := indexOf(from.Files, p)
+		reason := from.Reasons[i]
+		from.Files = append(from.Files[:i], from.Files[i+1:]...)
+		from.Reasons = append(from.Reasons[:i], from.Reasons[i+1:]...)
+		from.Signals = append(from.Signals[:i], from.Signals[i+1:]...)
+		to.Files = append(to.Files, p)
+		to.Reasons = append(to.Reasons, fmt.Sprintf("%s, committed with its source %s", reason, source))
+		to.Signals = append(to.Signals, fmt.Sprintf("%s: %s 0.90 (%s)", p, to.Type, derived[p]))
+		log.Debug("Attached %s to '%s' with its source %s", p, to.Key(), source)
+		if len(from.Files) == 0 {
+			delete(groups, deps.Key())
+		}
+	}
+}
+
+// sourceOf finds the changed file p is derived from and its group.
+func sourceOf(groups map[string]*Group, derived map[string]string, p, kind string) (string, *Group) {
+	dir := path.Dir(p)
+	var candidates []string
+	switch kind {
+	case Lockfile:
+		for _, m := range lockfileSources[path.Base(p)] {
+			candidates = append(candidates, path.Join(dir, m))
+		}
+	case Vendored:
+		root := strings.TrimSuffix(vendorRoot(p), "/")
+		for _, m := range vendorDirs[path.Base(root)] {
+			candidates = append(candidates, path.Join(path.Dir(root), m))
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, f := range groups[k].Files {
+			if _, ok := derived[f]; ok {
+				continue
+			}
+			switch {
+			case contains(candidates, f):
+			case kind == Generated && strings.HasSuffix(p, ".pb.go") && path.Ext(f) == ".proto" &&
+				strings.TrimSuffix(path.Base(f), ".proto") == strings.TrimSuffix(path.Base(p), ".pb.go"):
+			case kind == Generated && !strings.HasSuffix(p, ".pb.go") && path.Dir(f) == dir && path.Ext(f) == ".go":
+			default:
+				continue
+			}
+			return f, groups[k]
+		}
+	}
+	return "", nil
+}
+
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
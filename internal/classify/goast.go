@@ -0,0 +1,203 @@
+package classify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+)
+
+// decl is a top-level Go declaration reduced to what classification
+// compares: its signature (or full spec for types, vars and consts) and,
+// for funcs, its body and how many branches and returns the body has.
+type decl struct {
+	exported  bool
+	signature string
+	body      string
+	branches  int
+}
+
+// classifyGo compares the declarations of a Go file at HEAD with the working
+// tree. It reports false when declarations did not change, e.g. only
+// comments or imports did, or when either version does not parse.
+func classifyGo(path string) (Classification, bool) {
+	before, err := goDecls(git.ShowHead(path))
+	if err != nil {
+		return Classification{}, false
+	}
+	after, err := goDecls(ioutil.ReadFile(path))
+	if err != nil {
+		return Classification{}, false
+	}
+
+	var added, removed, resigned, rebodied []string
+	addedExported, moreBranches := false, false
+	for name, a := range after {
+		b, ok := before[name]
+		switch {
+		case !ok:
+			added = append(added, name)
+			addedExported = addedExported || a.exported
+		case a.signature != b.signature:
+			resigned = append(resigned, name)
+		case a.body != b.body:
+			rebodied = append(rebodied, name)
+			moreBranches = moreBranches || a.branches > b.branches
+		}
+	}
+	var removedExported []string
+	for name, b := range before {
+		if _, ok := after[name]; !ok {
+			removed = append(removed, name)
+			if b.exported {
+				removedExported = append(removedExported, name)
+			}
+		}
+	}
+	for _, list := range [][]string{added, removed, resigned, rebodied, removedExported} {
+		sort.Strings(list)
+	}
+
+	c := Classification{}
+	switch {
+	case len(before) == 0 && len(after) == 0:
+		return Classification{}, false
+	case addedExported:
+		c.Type, c.Reason, c.Confidence = "feat", "adds exported "+exportedOnly(added, after), 0.9
+	case len(removedExported) > 0:
+		c.Type, c.Reason, c.Confidence = "refactor", "removes exported "+list(removedExported), 0.85
+	case len(resigned) > 0:
+		c.Type, c.Reason, c.Confidence = "refactor", "changes the signature of "+list(resigned), 0.8
+	case len(added) > 0 && len(rebodied) > 0:
+		c.Type, c.Reason, c.Confidence = "refactor", fmt.Sprintf("extracts %s from %s", list(added), list(rebodied)), 0.75
+	case len(added) > 0:
+		c.Type, c.Reason, c.Confidence = "feat", "adds "+list(added), 0.7
+	case len(rebodied) > 0 && moreBranches:
+		c.Type, c.Reason, c.Confidence = "fix", "adds checks to "+list(rebodied)+" without changing signatures", 0.55
+	case len(rebodied) > 0:
+		c.Type, c.Reason, c.Confidence = "refactor", "changes the body of "+list(rebodied)+" without changing signatures", 0.5
+	case len(removed) > 0:
+		c.Type, c.Reason, c.Confidence = "refactor", "removes "+list(removed), 0.7
+	default:
+		return Classification{}, false
+	}
+	if len(removedExported) > 0 && !strings.HasPrefix(c.Reason, "removes exported") {
+		c.Reason += "; removes exported " + list(removedExported)
+	}
+	c.Signals = []string{"AST"}
+	return c, true
+}
+
+// goDecls parses a Go source file and indexes its top-level declarations.
+// Missing sources (a file added or deleted since HEAD) have no declarations.
+func goDecls(src []byte, readErr error) (map[string]decl, error) {
+	decls := make(map[string]decl)
+	if readErr != nil {
+		if errors.Is(readErr, os.ErrNotExist) {
+			return decls, nil
+		}
+		return nil, readErr
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range file.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = receiverName(d.Recv.List[0].Type) + "." + name
+			}
+			sig := *d
+			sig.Body, sig.Doc = nil, nil
+			info := decl{exported: d.Name.IsExported(), signature: render(fset, &sig)}
+			if d.Body != nil {
+				info.body = render(fset, d.Body)
+				info.branches = countBranches(d.Body)
+			}
+			decls[name] = info
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					decls[s.Name.Name] = decl{exported: s.Name.IsExported(), signature: render(fset, s)}
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						decls[n.Name] = decl{exported: n.IsExported(), signature: d.Tok.String() + " " + render(fset, s)}
+					}
+				}
+			}
+		}
+	}
+	return decls, nil
+}
+
+// receiverName returns the type name of a method receiver, without
+// pointers or type parameters.
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+// countBranches counts the if statements, switch cases and returns in a
+// body. More of them after a change suggests new error handling or guards.
+func countBranches(body *ast.BlockStmt) int {
+	n := 0
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.IfStmt, *ast.CaseClause, *ast.ReturnStmt:
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+func render(fset *token.FileSet, node interface{}) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// exportedOnly lists the exported names among names.
+func exportedOnly(names []string, decls map[string]decl) string {
+	var exported []string
+	for _, n := range names {
+		if decls[n].exported {
+			exported = append(exported, n)
+		}
+	}
+	return list(exported)
+}
+
+// list joins names for a reason, shortening long lists.
+func list(names []string) string {
+	if len(names) > 3 {
+		return fmt.Sprintf("%s and %d more", strings.Join(names[:3], ", "), len(names)-3)
+	}
+	return strings.Join(names, ", ")
+}
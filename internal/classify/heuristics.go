@@ -0,0 +1,62 @@
+package classify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+// revertDepth bounds how far back the history of changed files is searched
+// for the commits a change might undo.
+const revertDepth = 200
+
+// findReverts reports the files whose new content is what it was before
+// the last commit that touched them, i.e. changes that undo that commit.
+// Deleted files never count: a plain deletion of a file added in one commit
+// would otherwise look like a revert. The history and old contents of all
+// files are read with one git log and one git cat-file.
+func findReverts(log logger.Logger, changes []FileChange) map[string]Classification {
+	var paths []string
+	for _, change := range changes {
+		if !change.Deleted() && !strings.HasSuffix(change.Path, "/") {
+			paths = append(paths, change.Path)
+		}
+	}
+	reverts := make(map[string]Classification)
+	last, err := git.LastChanges(paths, revertDepth)
+	if err != nil {
+		log.Debug("Not looking for reverts: %v", err)
+		return reverts
+	}
+	var specs []string
+	for _, p := range paths {
+		if c, ok := last[p]; ok && len(c.Parents) > 0 {
+			specs = append(specs, c.Parents[0]+":"+p)
+		}
+	}
+	before, err := git.ShowBatch(specs)
+	if err != nil {
+		log.Debug("Not looking for reverts: %v", err)
+		return reverts
+	}
+	for _, p := range paths {
+		c, ok := last[p]
+		if !ok || len(c.Parents) == 0 {
+			continue
+		}
+		old, existed := before[c.Parents[0]+":"+p]
+		if !existed {
+			continue
+		}
+		current, err := ioutil.ReadFile(p)
+		if err != nil || !bytes.Equal(old, current) {
+			continue
+		}
+		reverts[p] = Classification{Type: "revert", Reason: fmt.Sprintf("undoes %.7s %q", c.Hash, c.Subject)}
+	}
+	return reverts
+}
@@ -0,0 +1,290 @@
+package classify
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+// moduleRe finds the module path in a go.mod file.
+var moduleRe = regexp.MustCompile(`(?m)^module\s+"?([^"\s]+)"?`)
+
+// typePriority orders the types a merged group can take, strongest first.
+var typePriority = []string{"feat", "fix", "perf", "refactor"}
+
+// importEdge is a changed Go file importing the package of another changed
+// file. uses lists the identifiers it uses that the imported package's
+// change added or re-signed.
+type importEdge struct {
+	importer, imported string
+	uses               []string
+}
+
+// groupByImports moves Go files that depend on each other through imports
+// into one group when they share intent: the importer uses an identifier
+// the imported package's change added or changed, or both files have the
+// same type. A new function and its first caller so land in one commit.
+// Only the connected files move; the rest of their groups stay put. Test
+// files are left in their own groups.
+func groupByImports(log logger.Logger, groups map[string]*Group) {
+	edges := importEdges(log, groups)
+	if len(edges) == 0 {
+		return
+	}
+
+	fileGroup := make(map[string]string)
+	for key, g := range groups {
+		for _, f := range g.Files {
+			fileGroup[f] = key
+		}
+	}
+
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(f string) string {
+		if p, ok := parent[f]; ok && p != f {
+			root := find(p)
+			parent[f] = root
+			return root
+		}
+		return f
+	}
+
+	var linked []string
+	notes := make(map[string][]string)
+	for _, e := range edges {
+		a, b := fileGroup[e.importer], fileGroup[e.imported]
+		if a == b || (len(e.uses) == 0 && groups[a].Type != groups[b].Type) {
+			continue
+		}
+		if ra, rb := find(e.importer), find(e.imported); ra != rb {
+			parent[ra] = rb
+		}
+		linked = append(linked, e.importer, e.imported)
+		note := fmt.Sprintf("%s: grouped with %s, whose package it imports", e.importer, e.imported)
+		if len(e.uses) > 0 {
+			note += " and whose new " + list(e.uses) + " it uses"
+		}
+		notes[e.importer] = append(notes[e.importer], note)
+	}
+
+	components := make(map[string][]string)
+	for _, f := range linked {
+		root := find(f)
+		if !contains(components[root], f) {
+			components[root] = append(components[root], f)
+		}
+	}
+	roots := make([]string, 0, len(components))
+	for root := range components {
+		roots = append(roots, root)
+	}
+	sort.Strings(roots)
+
+	// Move files first and add the notes after, so each group's Reasons
+	// and Signals still line up with its Files while files move.
+	winners := make(map[string]*Group)
+	for _, root := range roots {
+		files := components[root]
+		var keys []string
+		for _, f := range files {
+			if !contains(keys, fileGroup[f]) {
+				keys = append(keys, fileGroup[f])
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return strongerGroup(groups[keys[i]], groups[keys[j]]) })
+		winner := groups[keys[0]]
+		for _, f := range files {
+			winners[f] = winner
+			if fileGroup[f] == keys[0] {
+				continue
+			}
+			moveFile(groups, fileGroup[f], winner, f)
+			log.Debug("Moved %s into '%s' by imports", f, winner.Key())
+		}
+	}
+	for _, f := range linked {
+		winners[f].Reasons = append(winners[f].Reasons, notes[f]...)
+		delete(notes, f)
+	}
+}
+
+// moveFile moves file with its reason and signal from the group under key
+// into to, dropping the group once it is empty.
+func moveFile(groups map[string]*Group, key string, to *Group, file string) {
+	from := groups[key]
+	i := indexOf(from.Files, file)
+	to.Files = append(to.Files, file)
+	to.Reasons = append(to.Reasons, from.Reasons[i])
+	to.Signals = append(to.Signals, from.Signals[i])
+	to.Confidence = math.Min(to.Confidence, signalConfidence(from.Signals[i], file))
+	from.Files = append(from.Files[:i], from.Files[i+1:]...)
+	from.Reasons = append(from.Reasons[:i], from.Reasons[i+1:]...)
+	from.Signals = append(from.Signals[:i], from.Signals[i+1:]...)
+	if len(from.Files) == 0 {
+		delete(groups, key)
+		return
+	}
+	from.Confidence = 1
+	for j, line := range from.Signals {
+		from.Confidence = math.Min(from.Confidence, signalConfidence(line, from.Files[j]))
+	}
+}
+
+// signalConfidence reads the confidence from file's "path: type
+// confidence (signals)" line.
+func signalConfidence(line, file string) float64 {
+	var commitType string
+	var confidence float64
+	fmt.Sscanf(strings.TrimPrefix(line, file+": "), "%s %f", &commitType, &confidence)
+	return confidence
+}
+
+// strongerGroup orders groups for picking the one a merge keeps: the
+// stronger type first, then the larger group, then by key.
+func strongerGroup(a, b *Group) bool {
+	pa, pb := priority(a.Type), priority(b.Type)
+	switch {
+	case pa != pb:
+		return pa < pb
+	case len(a.Files) != len(b.Files):
+		return len(a.Files) > len(b.Files)
+	}
+	return a.Key() < b.Key()
+}
+
+func priority(commitType string) int {
+	for i, t := range typePriority {
+		if t == commitType {
+			return i
+		}
+	}
+	return len(typePriority)
+}
+
+// importEdges finds, among the non-test Go files in groups, the ones that
+// import the package of another.
+func importEdges(log logger.Logger, groups map[string]*Group) []importEdge {
+	modulePath := readModulePath()
+	if modulePath == "" {
+		return nil
+	}
+
+	byDir := make(map[string][]string)
+	for _, g := range groups {
+		for _, f := range g.Files {
+			if isAPIFile(f) {
+				byDir[path.Dir(f)] = append(byDir[path.Dir(f)], f)
+			}
+		}
+	}
+
+	// Package names and the identifiers each changed package added or
+	// re-signed, computed once per directory.
+	pkgNames := make(map[string]string)
+	newIdents := make(map[string]map[string]bool)
+	for dir := range byDir {
+		pkg, before := headAPI(dir)
+		p, after := worktreeAPI(dir)
+		if p != "" {
+			pkg = p
+		}
+		pkgNames[dir] = pkg
+		newIdents[dir] = make(map[string]bool)
+		for ident, a := range after {
+			if b, ok := before[ident]; (!ok || b.signature != a.signature) && !strings.Contains(ident, ".") {
+				newIdents[dir][ident] = true
+			}
+		}
+	}
+
+	var edges []importEdge
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		for _, file := range byDir[dir] {
+			src, err := ioutil.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			f, err := parser.ParseFile(token.NewFileSet(), file, src, 0)
+			if err != nil {
+				log.Debug("Skipping imports of %s: %v", file, err)
+				continue
+			}
+			for _, spec := range f.Imports {
+				importPath, _ := strconv.Unquote(spec.Path.Value)
+				target, ok := moduleDir(modulePath, importPath)
+				if !ok || target == dir || len(byDir[target]) == 0 {
+					continue
+				}
+				name := pkgNames[target]
+				if spec.Name != nil {
+					name = spec.Name.Name
+				}
+				uses := usedIdents(f, name, newIdents[target])
+				for _, imported := range byDir[target] {
+					edges = append(edges, importEdge{importer: file, imported: imported, uses: uses})
+				}
+			}
+		}
+	}
+	return edges
+}
+
+// usedIdents returns the identifiers of idents that f refers to through the
+// import named name, e.g. "Open" for store.Open.
+func usedIdents(f *ast.File, name string, idents map[string]bool) []string {
+	seen := make(map[string]bool)
+	var uses []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok && x.Name == name && idents[sel.Sel.Name] && !seen[sel.Sel.Name] {
+			seen[sel.Sel.Name] = true
+			uses = append(uses, sel.Sel.Name)
+		}
+		return true
+	})
+	sort.Strings(uses)
+	return uses
+}
+
+// readModulePath returns the module path declared in ./go.mod, or "".
+func readModulePath() string {
+	data, err := ioutil.ReadFile("go.mod")
+	if err != nil {
+		return ""
+	}
+	if m := moduleRe.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// moduleDir maps an import path inside the module to its directory,
+// relative to the module root.
+func moduleDir(modulePath, importPath string) (string, bool) {
+	switch {
+	case importPath == modulePath:
+		return ".", true
+	case strings.HasPrefix(importPath, modulePath+"/"):
+		return strings.TrimPrefix(importPath, modulePath+"/"), true
+	}
+	return "", false
+}
@@ -0,0 +1,110 @@
+package classify
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+)
+
+// compiledRule is a configured classification rule ready to match. globs
+// holds one regexp per entry of Paths, in the same order.
+type compiledRule struct {
+	config.ClassifyRule
+	label string
+	globs []*regexp.Regexp
+	diff  *regexp.Regexp
+}
+
+// compileRules compiles the configured rules and sorts them by descending
+// priority, keeping file order for equal priorities. Rules are validated
+// when the config is loaded, so invalid patterns are skipped here.
+func compileRules(rules []config.ClassifyRule) []compiledRule {
+	var compiled []compiledRule
+	for i, r := range rules {
+		c := compiledRule{ClassifyRule: r, label: fmt.Sprintf("rule %d", i+1)}
+		if r.Name != "" {
+			c.label = fmt.Sprintf("rule %q", r.Name)
+		}
+		for _, g := range r.Paths {
+			c.globs = append(c.globs, globRegexp(g))
+		}
+		if r.Diff != "" {
+			re, err := regexp.Compile(r.Diff)
+			if err != nil {
+				continue
+			}
+			c.diff = re
+		}
+		compiled = append(compiled, c)
+	}
+	sort.SliceStable(compiled, func(i, j int) bool { return compiled[i].Priority > compiled[j].Priority })
+	return compiled
+}
+
+// match reports whether the rule applies to change, whose patch is given.
+func (r compiledRule) match(change FileChange, patch string) bool {
+	if len(r.globs) > 0 {
+		matched := false
+		for i, g := range r.globs {
+			if g.MatchString(change.Path) || (!strings.Contains(r.Paths[i], "/") && g.MatchString(path.Base(change.Path))) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(r.Status) > 0 && !contains(r.Status, statusName(change)) {
+		return false
+	}
+	return r.diff == nil || r.diff.MatchString(patch)
+}
+
+// classification returns what the rule assigns.
+func (r compiledRule) classification() Classification {
+	return Classification{Type: r.Type, Scope: r.Scope, Reason: "matches " + r.label + " in the config"}
+}
+
+// statusName maps a porcelain status to the names rules filter on.
+func statusName(change FileChange) string {
+	switch {
+	case change.Added():
+		return "added"
+	case change.Deleted():
+		return "deleted"
+	case change.Renamed():
+		return "renamed"
+	}
+	return "modified"
+}
+
+// globRegexp translates a path glob into an anchored regexp. `*` and `?`
+// stay within one path segment and `**` spans any number of them. A
+// pattern without a slash is matched against the base name as well.
+func globRegexp(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// commitlintConfigFiles lists the static commitlint configuration files we
+// can read. JavaScript and TypeScript configs cannot be evaluated and are
+// ignored unless they happen to be JSON.
+var commitlintConfigFiles = []string{
+	".commitlintrc",
+	".commitlintrc.json",
+	".commitlintrc.yaml",
+	".commitlintrc.yml",
+	"package.json",
+}
+
+// commitlintConfig is the subset of commitlint configuration we compile.
+type commitlintConfig struct {
+	Extends []string
+	Rules   map[string]interface{}
+}
+
+// decodeCommitlintConfig reads a commitlint configuration from a JSON or
+// YAML file, or from the "commitlint" key of package.json. It returns nil
+// when the file holds no commitlint configuration.
+func decodeCommitlintConfig(path string, content []byte) (*commitlintConfig, error) {
+	var raw map[string]interface{}
+	name := filepath.Base(path)
+
+	switch {
+	case name == "package.json":
+		var pkg map[string]interface{}
+		if err := json.Unmarshal(content, &pkg); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		section, ok := pkg["commitlint"].(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		raw = section
+	case strings.HasSuffix(name, ".json"):
+		if err := json.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case strings.HasSuffix(name, ".yaml"), strings.HasSuffix(name, ".yml"), name == ".commitlintrc":
+		// YAML is a superset of JSON, so this also covers a JSON .commitlintrc.
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	default:
+		return nil, nil
+	}
+
+	cfg := &commitlintConfig{Rules: map[string]interface{}{}}
+	switch extends := raw["extends"].(type) {
+	case string:
+		cfg.Extends = []string{extends}
+	case []interface{}:
+		for _, e := range extends {
+			if s, ok := e.(string); ok {
+				cfg.Extends = append(cfg.Extends, s)
+			}
+		}
+	}
+	if rules, ok := raw["rules"].(map[string]interface{}); ok {
+		cfg.Rules = rules
+	}
+	return cfg, nil
+}
+
+// applyCommitlintConfig compiles commitlint rules into CommitRules. Each rule
+// is an array of [level, applicable, value]; level 0 disables the rule and
+// level 1 makes it a warning.
+func applyCommitlintConfig(rules *CommitRules, cfg *commitlintConfig) error {
+	for _, preset := range cfg.Extends {
+		if strings.Contains(preset, "config-conventional") {
+			applyConventionalPreset(rules)
+		}
+	}
+
+	for name, spec := range cfg.Rules {
+		parts, ok := spec.([]interface{})
+		if !ok || len(parts) == 0 {
+			return fmt.Errorf("rule %s: expected [level, applicable, value]", name)
+		}
+		level, ok := toInt(parts[0])
+		if !ok {
+			return fmt.Errorf("rule %s: invalid level %v", name, parts[0])
+		}
+		never := len(parts) > 1 && parts[1] == "never"
+		var value interface{}
+		if len(parts) > 2 {
+			value = parts[2]
+		}
+
+		delete(rules.Warnings, name)
+		if level == 1 {
+			rules.Warnings[name] = true
+		}
+		enabled := level > 0
+
+		switch name {
+		case "type-enum":
+			rules.Types = nil
+			if enabled && !never {
+				rules.Types = toStrings(value)
+			}
+		case "scope-enum":
+			rules.Scopes = nil
+			if enabled && !never {
+				rules.Scopes = toStrings(value)
+			}
+		case "type-case":
+			rules.TypeCase = ""
+			if cases := toStrings(value); enabled && !never && len(cases) > 0 {
+				rules.TypeCase = cases[0]
+			}
+		case "scope-empty":
+			rules.ScopeRequired = enabled && never
+		case "header-max-length":
+			rules.HeaderMaxLength = lengthRule(enabled, value)
+		case "header-min-length":
+			rules.HeaderMinLength = lengthRule(enabled, value)
+		case "subject-max-length":
+			rules.SubjectMaxLength = lengthRule(enabled, value)
+		case "body-max-line-length":
+			rules.BodyMaxLineLength = lengthRule(enabled, value)
+		case "footer-max-line-length":
+			rules.FooterMaxLineLength = lengthRule(enabled, value)
+		case "subject-case":
+			rules.SubjectCase = CaseRule{}
+			if enabled {
+				rules.SubjectCase = CaseRule{Never: never, Cases: toStrings(value)}
+			}
+		case "subject-full-stop":
+			rules.SubjectFullStop = ""
+			if s, ok := value.(string); enabled && never && ok {
+				rules.SubjectFullStop = s
+			}
+		case "body-leading-blank":
+			rules.BodyLeadingBlank = enabled && !never
+		}
+	}
+	return nil
+}
+
+// applyConventionalPreset mirrors @commitlint/config-conventional.
+func applyConventionalPreset(rules *CommitRules) {
+	defaults := DefaultCommitRules()
+	rules.Types = defaults.Types
+	rules.TypeCase = "lower-case"
+	rules.HeaderMaxLength = 100
+	rules.BodyMaxLineLength = 100
+	rules.FooterMaxLineLength = 100
+	rules.BodyLeadingBlank = true
+	rules.Warnings["body-leading-blank"] = true
+	rules.SubjectCase = defaults.SubjectCase
+	rules.SubjectFullStop = "."
+}
+
+func lengthRule(enabled bool, value interface{}) int {
+	if !enabled {
+		return 0
+	}
+	n, _ := toInt(value)
+	return n
+}
+
+// toInt accepts the numeric types produced by both the JSON and YAML decoders.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
+
+// toStrings accepts a single string or a list of strings.
+func toStrings(v interface{}) []string {
+	switch list := v.(type) {
+	case string:
+		return []string{list}
+	case []interface{}:
+		var out []string
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -5,7 +5,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath" // Added for filepath.Glob
+	"reflect"
 	"regexp" // Added for regexp.MustCompile
+	"sort"
+	"strconv"
 	"strings" // Already present, but ensuring it's there
 
 	"github.com/BurntSushi/toml"
@@ -13,54 +16,291 @@ import (
 
 // Config holds the application's configuration settings.
 type Config struct {
-	AutoPush       bool `toml:"auto_push"`
-	ReviewMode     bool `toml:"review_mode"`
+	AutoPush         bool `toml:"auto_push"`
+	ReviewMode       bool `toml:"review_mode"`
 	LearnFromHistory bool `toml:"learn_from_history"`
-	AICommit       bool `toml:"ai_commit"`
-	CI             bool `toml:"ci"`
-	Verbose        bool `toml:"verbose"`
+	AICommit         bool `toml:"ai_commit"`
+	CI               bool `toml:"ci"`
+	Verbose          bool `toml:"verbose"`
+	// FewShotExamples is how many past commits are shown to the AI as
+	// examples; 0 disables them.
+	FewShotExamples int `toml:"few_shot_examples"`
+	// MinConfidence is the classification confidence below which a group
+	// is sent to AI if AILowConfidence is on, or to review when running
+	// interactively; 0 disables it.
+	MinConfidence float64 `toml:"min_confidence"`
+	// AILowConfidence lets a run with ai_commit off ask the AI for the
+	// message of each group below MinConfidence.
+	AILowConfidence bool `toml:"ai_low_confidence"`
+	// Rules are the [[rules]] tables that classify files before the
+	// built-in heuristics. A layer that sets rules replaces earlier ones.
+	Rules []ClassifyRule `toml:"rules"`
+	// Types adds custom commit types from a [types] table, each mapped to
+	// the subject used for it when AI is off.
+	Types map[string]string `toml:"types"`
+
+	// Origins records, for every key, the layer that last set its value.
+	Origins map[string]string `toml:"-"`
 }
 
-// LoadConfig reads the configuration from a .autocommitrc file.
-// It looks for the file in the current directory.
-func LoadConfig() (Config, error) {
-	var cfg Config
-	configPath := ".autocommitrc"
+// RepoConfigFile is the per-repository configuration file.
+const RepoConfigFile = ".autocommitrc"
 
-	// Set default values
-	cfg.AutoPush = true
-	cfg.ReviewMode = false
-	cfg.LearnFromHistory = true
-	cfg.AICommit = false
-	cfg.CI = false
-	cfg.Verbose = false
+// envPrefix is prepended to the upper-cased key to form environment variable names.
+const envPrefix = "AUTOCOMMIT_"
 
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		// If config file doesn't exist, return default config
-		return cfg, nil
+// defaultConfig returns the built-in defaults.
+func defaultConfig() Config {
+	return Config{
+		AutoPush:         true,
+		ReviewMode:       false,
+		LearnFromHistory: true,
+		AICommit:         true,
+		CI:               false,
+		Verbose:          false,
+		FewShotExamples:  3,
 	}
+}
 
-	// Read and parse the config file
-	configData, err := ioutil.ReadFile(configPath)
-	if err != nil {
-		return cfg, fmt.Errorf("failed to read config file %s: %w", configPath, err)
+// UserConfigPath returns the user-global configuration file,
+// $XDG_CONFIG_HOME/autocommit/config.toml (~/.config when XDG_CONFIG_HOME is unset).
+func UserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "autocommit", "config.toml")
+}
+
+// LoadConfig builds the configuration from its layers, each overriding the
+// previous one: built-in defaults, the user-global file, the repository's
+// .autocommitrc, AUTOCOMMIT_* environment variables, and finally flags.
+// flags maps config keys to the raw values given on the command line.
+func LoadConfig(flags map[string]string) (Config, error) {
+	cfg := defaultConfig()
+	cfg.Origins = map[string]string{}
+	for _, key := range Keys() {
+		cfg.Origins[key] = "default"
 	}
 
-	if _, err := toml.Decode(string(configData), &cfg); err != nil {
-		return cfg, fmt.Errorf("failed to decode config file %s: %w", configPath, err)
+	if path := UserConfigPath(); path != "" {
+		if err := loadConfigFile(&cfg, path, "user"); err != nil {
+			return cfg, err
+		}
+	}
+	if err := loadConfigFile(&cfg, RepoConfigFile, "repo"); err != nil {
+		return cfg, err
+	}
+
+	for _, key := range Keys() {
+		name := envPrefix + strings.ToUpper(key)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := cfg.set(key, value); err != nil {
+				return cfg, fmt.Errorf("invalid %s: %w", name, err)
+			}
+			cfg.Origins[key] = "env " + name
+		}
+	}
+
+	for key, value := range flags {
+		if err := cfg.set(key, value); err != nil {
+			return cfg, fmt.Errorf("invalid flag for %s: %w", key, err)
+		}
+		cfg.Origins[key] = "flag"
 	}
 
 	return cfg, nil
 }
 
+// loadConfigFile decodes a TOML file over cfg, recording the keys it sets.
+// A missing file is not an error.
+func loadConfigFile(cfg *Config, path, layer string) error {
+	configData, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	rules := cfg.Rules
+	cfg.Rules = nil
+	meta, err := toml.Decode(string(configData), cfg)
+	if err != nil {
+		return fmt.Errorf("failed to decode config file %s: %w", path, err)
+	}
+	if !meta.IsDefined("rules") {
+		cfg.Rules = rules
+	}
+	for i, rule := range cfg.Rules {
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("invalid rule %d in %s: %w", i+1, path, err)
+		}
+	}
+	for _, key := range meta.Keys() {
+		cfg.Origins[key.String()] = fmt.Sprintf("%s %s", layer, path)
+	}
+	return nil
+}
+
+// Keys returns the scalar configuration keys in declaration order. Tables
+// such as [[rules]] and [types] can only be set in config files and are
+// not listed.
+func Keys() []string {
+	var keys []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("toml"); tag != "" && tag != "-" && t.Field(i).Type.Kind() != reflect.Slice && t.Field(i).Type.Kind() != reflect.Map {
+			keys = append(keys, tag)
+		}
+	}
+	return keys
+}
+
+// Get returns the value of a configuration key formatted as a string.
+func (c Config) Get(key string) string {
+	if field, ok := c.field(key); ok {
+		return fmt.Sprint(field.Interface())
+	}
+	return ""
+}
+
+// set parses a raw string value into the field tagged with key.
+func (c *Config) set(key, value string) error {
+	field, ok := c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	switch field.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.String:
+		field.SetString(value)
+	default:
+		return fmt.Errorf("config key %q cannot be set from a string", key)
+	}
+	return nil
+}
+
+func (c *Config) field(key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("toml") == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
 // CommitRules holds the extracted static rules for commit message validation.
+// The fields mirror the commitlint rules of the same name; a zero value
+// disables the corresponding check.
 type CommitRules struct {
 	CommitMessageRegex string
+	// Types lists the allowed commit types (type-enum). Empty allows any type.
+	Types []string
+	// Scopes lists the allowed scopes (scope-enum). Empty allows any scope.
+	Scopes []string
+	// TypeCase is the required case of the type, e.g. "lower-case".
+	TypeCase string
+	// ScopeRequired rejects headers without a scope (scope-empty: never).
+	ScopeRequired bool
+	// HeaderMaxLength and HeaderMinLength bound the length of the first line.
+	HeaderMaxLength int
+	HeaderMinLength int
+	// SubjectMaxLength bounds the length of the subject alone.
+	SubjectMaxLength int
+	// SubjectCase constrains the case of the subject.
+	SubjectCase CaseRule
+	// SubjectFullStop is a character the subject must not end with.
+	SubjectFullStop string
+	// BodyLeadingBlank requires a blank line between header and body.
+	BodyLeadingBlank bool
+	// BodyMaxLineLength and FooterMaxLineLength bound line lengths below the header.
+	BodyMaxLineLength   int
+	FooterMaxLineLength int
+	// Warnings holds the names of rules configured at warning level; their
+	// violations are reported but do not fail validation.
+	Warnings map[string]bool
+}
+
+// CaseRule is a commitlint case condition. With Never unset the value must
+// match one of Cases; with Never set it must match none of them.
+type CaseRule struct {
+	Never bool
+	Cases []string
+}
+
+// DefaultCommitRules returns the Conventional Commits rules applied when a
+// repository does not define its own.
+func DefaultCommitRules() CommitRules {
+	return CommitRules{
+		Types:           []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"},
+		TypeCase:        "lower-case",
+		HeaderMaxLength: 72,
+		SubjectCase:     CaseRule{Never: true, Cases: []string{"sentence-case", "start-case", "pascal-case", "upper-case"}},
+		SubjectFullStop: ".",
+		Warnings:        map[string]bool{},
+	}
 }
 
-// ParseCommitGuides parses the detected commit guide files to extract static rules.
+// LoadCommitRules detects the repository's commit guides and compiles them
+// on top of the default rules. The custom types in cfg are allowed as well.
+func LoadCommitRules(cfg Config) (CommitRules, error) {
+	guides, err := DetectCommitGuides()
+	if err != nil {
+		return DefaultCommitRules(), err
+	}
+	rules, err := ParseCommitGuides(guides)
+	if len(rules.Types) > 0 {
+		rules.Types = append(rules.Types, cfg.customTypes(rules.Types)...)
+	}
+	return rules, err
+}
+
+// customTypes returns the sorted names of the custom types that are not
+// already in known.
+func (c Config) customTypes(known []string) []string {
+	var names []string
+	for name := range c.Types {
+		found := false
+		for _, k := range known {
+			found = found || k == name
+		}
+		if !found {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseCommitGuides parses the detected commit guide files to extract static
+// rules. commitlint configuration is compiled rule by rule; CONTRIBUTING.md
+// can only contribute a header regex.
 func ParseCommitGuides(guides []string) (CommitRules, error) {
-	var rules CommitRules
+	rules := DefaultCommitRules()
 
 	for _, guidePath := range guides {
 		content, err := ioutil.ReadFile(guidePath)
@@ -76,22 +316,17 @@ func ParseCommitGuides(guides []string) (CommitRules, error) {
 			matches := re.FindStringSubmatch(string(content))
 			if len(matches) > 1 {
 				rules.CommitMessageRegex = strings.TrimSpace(matches[1])
-				fmt.Printf("Extracted commit message regex from CONTRIBUTING.md: %s\n", rules.CommitMessageRegex)
-				// Prioritize CONTRIBUTING.md if found
-				return rules, nil
 			}
+			continue
 		}
 
-		// Regex-based extraction for commitlint.config.* (assuming JSON for simplicity)
-		if strings.HasPrefix(guidePath, "commitlint.config.") && strings.HasSuffix(guidePath, ".json") {
-			// Attempt to extract a regex from a JSON structure
-			// This is also highly brittle and assumes a specific JSON path.
-			re := regexp.MustCompile(`"pattern":\s*"(.*?)"`)
-			matches := re.FindStringSubmatch(string(content))
-			if len(matches) > 1 {
-				rules.CommitMessageRegex = strings.TrimSpace(matches[1])
-				fmt.Printf("Extracted commit message regex from %s: %s\n", guidePath, rules.CommitMessageRegex)
-				return rules, nil
+		cfg, err := decodeCommitlintConfig(guidePath, content)
+		if err != nil {
+			return rules, err
+		}
+		if cfg != nil {
+			if err := applyCommitlintConfig(&rules, cfg); err != nil {
+				return rules, fmt.Errorf("invalid commitlint config in %s: %w", guidePath, err)
 			}
 		}
 	}
@@ -108,6 +343,13 @@ func DetectCommitGuides() ([]string, error) {
 		guides = append(guides, "CONTRIBUTING.md")
 	}
 
+	// Check for commitlint configuration, in commitlint's own lookup order
+	for _, name := range commitlintConfigFiles {
+		if _, err := os.Stat(name); err == nil {
+			guides = append(guides, name)
+		}
+	}
+
 	// Check for commitlint.config.* files
 	matches, err := filepath.Glob("commitlint.config.*")
 	if err != nil {
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ClassifyRule is a [[rules]] table in a config file. A rule matches a
+// changed file when every condition it sets holds: the path matches one of
+// Paths, the file's diff matches Diff, and its status is one of Status
+// ("added", "modified", "deleted" or "renamed"). Rules are tried in
+// descending Priority, in file order for equal priorities, and the first
+// match decides the file's type and, if set, its scope.
+//
+//	[[rules]]
+//	name = "database"
+//	paths = ["db/**", "*.sql"]
+//	status = ["added"]
+//	type = "feat"
+//	scope = "db"
+//	priority = 10
+type ClassifyRule struct {
+	Name     string   `toml:"name"`
+	Paths    []string `toml:"paths"`
+	Diff     string   `toml:"diff"`
+	Status   []string `toml:"status"`
+	Type     string   `toml:"type"`
+	Scope    string   `toml:"scope"`
+	Priority int      `toml:"priority"`
+}
+
+// ruleStatuses are the values a rule's status filter accepts.
+var ruleStatuses = map[string]bool{"added": true, "modified": true, "deleted": true, "renamed": true}
+
+func (r ClassifyRule) validate() error {
+	if r.Type == "" {
+		return fmt.Errorf("type is required")
+	}
+	if len(r.Paths) == 0 && r.Diff == "" && len(r.Status) == 0 {
+		return fmt.Errorf("at least one of paths, diff and status is required")
+	}
+	if _, err := regexp.Compile(r.Diff); err != nil {
+		return fmt.Errorf("invalid diff pattern: %w", err)
+	}
+	for _, s := range r.Status {
+		if !ruleStatuses[s] {
+			return fmt.Errorf("unknown status %q (want added, modified, deleted or renamed)", s)
+		}
+	}
+	return nil
+}
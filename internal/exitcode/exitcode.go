@@ -0,0 +1,90 @@
+package exitcode
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies why a run ended. Every kind maps to a fixed, documented
+// process exit code so CI pipelines can branch on the result.
+type Kind int
+
+const (
+	Success Kind = iota
+	// Failure is any error not covered by a more specific kind.
+	Failure
+	NoChanges
+	UnsafeGitState
+	AIFailure
+	RuleViolation
+	PushRejected
+	SecretDetected
+	// InvariantViolation means a code path broke a run-mode invariant, such
+	// as reading stdin or writing the cache in CI mode.
+	InvariantViolation
+)
+
+// kinds holds the exit code and the name reported in logs for each kind.
+var kinds = map[Kind]struct {
+	code int
+	name string
+}{
+	Success:            {0, "success"},
+	Failure:            {1, "error"},
+	NoChanges:          {2, "no_changes"},
+	UnsafeGitState:     {3, "unsafe_git_state"},
+	AIFailure:          {4, "ai_failure"},
+	RuleViolation:      {5, "rule_violation"},
+	PushRejected:       {6, "push_rejected"},
+	SecretDetected:     {7, "secret_detected"},
+	InvariantViolation: {8, "invariant_violation"},
+}
+
+// Code returns the process exit code for the kind.
+func (k Kind) Code() int {
+	return kinds[k].code
+}
+
+func (k Kind) String() string {
+	return kinds[k].name
+}
+
+// Error is an error tagged with the kind of failure it represents.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns an error of the given kind with a formatted message.
+func New(kind Kind, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap tags err with a kind. A nil err stays nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// KindOf returns the kind of err: Success for nil, the tagged kind for an
+// *Error anywhere in the chain, and Failure otherwise.
+func KindOf(err error) Kind {
+	if err == nil {
+		return Success
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return Failure
+}
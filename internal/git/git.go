@@ -1,8 +1,17 @@
 package git
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/urstruelysv/autocommit-cli/internal/logger"
@@ -55,20 +64,110 @@ func DetectChanges(log logger.Logger) (string, error) {
 	return changes, nil
 }
 
-func CommitChanges(log logger.Logger, message string, files []string) error {
+// Trailer marks the commits made by autocommit, so they can be told apart
+// from commits written by hand.
+const Trailer = "Generated-by: autocommit-cli"
+
+// CommitCommands returns the git commands CommitChanges runs for a group,
+// as argument lists without the leading "git". Files with a blob are staged
+// from it instead of from the working tree.
+func CommitCommands(message string, files []string, blobs []Blob) [][]string {
+	var commands [][]string
+	staged := make(map[string]bool)
+	for _, b := range blobs {
+		commands = append(commands, []string{"update-index", "--cacheinfo", b.Mode + "," + b.Hash + "," + b.Path})
+		staged[b.Path] = true
+	}
+	var add []string
+	for _, f := range files {
+		if !staged[f] {
+			add = append(add, f)
+		}
+	}
+	if len(add) > 0 {
+		commands = append(commands, append([]string{"add"}, add...))
+	}
+	return append(commands, []string{"commit", "-m", message, "--trailer", Trailer})
+}
+
+// Blob is content to commit for a path in place of its working tree
+// version.
+type Blob struct {
+	Path string
+	Mode string
+	Hash string
+}
+
+// Blobs hashes the content given for each path, keeping the file mode the
+// path has at HEAD. With write set the objects are stored in the
+// repository; otherwise only their hashes are computed.
+func Blobs(content map[string]string, write bool) ([]Blob, error) {
+	paths := make([]string, 0, len(content))
+	for p := range content {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var blobs []Blob
+	for _, p := range paths {
+		mode := "100644"
+		if output, err := exec.Command("git", "ls-tree", "HEAD", "--", p).Output(); err == nil && len(output) > 0 {
+			mode = strings.Fields(string(output))[0]
+		}
+		args := []string{"hash-object", "--stdin"}
+		if write {
+			args = append(args, "-w")
+		}
+		cmd := exec.Command("git", args...)
+		cmd.Stdin = strings.NewReader(content[p])
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("could not hash the content for %s: %w", p, err)
+		}
+		blobs = append(blobs, Blob{Path: p, Mode: mode, Hash: strings.TrimSpace(string(output))})
+	}
+	return blobs, nil
+}
+
+// HasTrailer reports whether message carries the autocommit trailer in its
+// last paragraph.
+func HasTrailer(message string) bool {
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if strings.TrimSpace(line) == Trailer {
+			return true
+		}
+	}
+	return false
+}
+
+// PushCommand returns the git command PushChanges runs.
+func PushCommand() []string {
+	return []string{"push"}
+}
+
+// CommitChanges stages files and commits them. content, when set for a
+// file, is committed in place of the file's working tree version.
+func CommitChanges(log logger.Logger, message string, files []string, content map[string]string) error {
 	log.Debug("Committing group with message: %s", message)
 	log.Info("\n--- Committing Group: %s ---", message)
 
-	addArgs := append([]string{"add"}, files...)
-	addCmd := exec.Command("git", addArgs...)
-	if output, err := addCmd.CombinedOutput(); err != nil {
-		log.Error("Error staging files %v: %s\n%v", files, string(output), err)
+	blobs, err := Blobs(content, true)
+	if err != nil {
+		log.Error("Error writing partial content for %v: %v", files, err)
 		return err
 	}
+	commands := CommitCommands(message, files, blobs)
+	for _, args := range commands[:len(commands)-1] {
+		if output, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			log.Error("Error staging files %v: %s\n%v", files, string(output), err)
+			return err
+		}
+	}
 	log.Debug("Staged files: %v", files)
 	log.Info("Staged files: %v", files)
 
-	commitCmd := exec.Command("git", "commit", "-m", message)
+	commitCmd := exec.Command("git", commands[len(commands)-1]...)
 	if output, err := commitCmd.CombinedOutput(); err != nil {
 		log.Error("Error committing group: %s\n%v", string(output), err)
 		return err
@@ -96,7 +195,7 @@ func PushChanges(log logger.Logger) error {
 
 	log.Debug("Pushing changes to remote for branch '%s'...", branchName)
 	log.Info("Pushing changes to remote for branch '%s'...", branchName)
-	pushCmd := exec.Command("git", "push")
+	pushCmd := exec.Command("git", PushCommand()...)
 	if output, err := pushCmd.CombinedOutput(); err != nil {
 		return fmt.Errorf("error during push: %s\n%w", string(output), err)
 	}
@@ -104,3 +203,520 @@ func PushChanges(log logger.Logger) error {
 	log.Info("Push successful.")
 	return nil
 }
+
+// StagedDiff returns the diff of the changes currently staged in the index.
+func StagedDiff(log logger.Logger, exclude ...string) (string, error) {
+	log.Debug("Reading staged diff...")
+	args := []string{"diff", "--cached"}
+	if len(exclude) > 0 {
+		args = append(args, "--", ".")
+		for _, path := range exclude {
+			args = append(args, ":(exclude,literal)"+path)
+		}
+	}
+	cmd := exec.Command("git", args...)
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not read staged diff: %w", err)
+	}
+	return string(output), nil
+}
+
+// WorkingDiff returns the diff of files in the working tree against HEAD.
+func WorkingDiff(log logger.Logger, files []string) (string, error) {
+	log.Debug("Reading working tree diff of %v...", files)
+	output, err := exec.Command("git", append([]string{"diff", "HEAD", "--"}, files...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not read working tree diff: %w", err)
+	}
+	return string(output), nil
+}
+
+// Patches maps each changed path to its patch.
+type Patches map[string]string
+
+// For returns the patch of path. For an untracked directory, listed by git
+// status as "dir/", it returns the patches of every file under it.
+func (p Patches) For(path string) string {
+	if !strings.HasSuffix(path, "/") {
+		return p[path]
+	}
+	var names []string
+	for name := range p {
+		if strings.HasPrefix(name, path) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(p[name])
+	}
+	return b.String()
+}
+
+// SnapshotDiff returns the working tree's changes against HEAD as one
+// zero-context patch per path, from a single git diff. Untracked files are
+// included by marking them intent-to-add in a temporary copy of the index,
+// so the real index is left alone.
+func SnapshotDiff(log logger.Logger, untracked []string) (Patches, error) {
+	log.Debug("Reading the snapshot diff...")
+	indexPath, err := exec.Command("git", "rev-parse", "--git-path", "index").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not locate the index: %w", err)
+	}
+	index, err := ioutil.ReadFile(strings.TrimSpace(string(indexPath)))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not read the index: %w", err)
+	}
+	tmp, err := ioutil.TempFile("", "autocommit-index-")
+	if err != nil {
+		return nil, fmt.Errorf("could not copy the index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(index)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not copy the index: %w", err)
+	}
+	env := append(os.Environ(), "GIT_INDEX_FILE="+tmp.Name())
+
+	var present []string
+	for _, path := range untracked {
+		if _, err := os.Lstat(path); err == nil {
+			present = append(present, path)
+		}
+	}
+	if len(present) > 0 {
+		addCmd := exec.Command("git", append([]string{"add", "--intent-to-add", "--"}, present...)...)
+		addCmd.Env = env
+		if output, err := addCmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("could not include untracked files: %s: %w", strings.TrimSpace(string(output)), err)
+		}
+	}
+	diffCmd := exec.Command("git", "diff", "HEAD", "--no-color", "--no-ext-diff", "--no-renames", "--unified=0")
+	diffCmd.Env = env
+	output, err := diffCmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the snapshot diff: %w", err)
+	}
+	return parsePatches(string(output)), nil
+}
+
+// parsePatches splits a diff into per-file patches. Without renames both
+// sides of a "diff --git a/P b/P" header name the same path, which gives
+// its length even when the path contains spaces.
+func parsePatches(diff string) Patches {
+	patches := Patches{}
+	var path string
+	var patch strings.Builder
+	flush := func() {
+		if path != "" {
+			patches[path] = patch.String()
+		}
+		patch.Reset()
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			names := strings.TrimSuffix(strings.TrimPrefix(line, "diff --git "), "\n")
+			path = ""
+			if n := (len(names) - 5) / 2; n > 0 && strings.HasPrefix(names, "a/") {
+				path = names[2 : 2+n]
+			}
+		}
+		patch.WriteString(line)
+	}
+	flush()
+	return patches
+}
+
+// HooksDir returns the directory git runs hooks from, honouring core.hooksPath.
+func HooksDir() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-path", "hooks")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not locate git hooks directory: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// CommitMessage is a commit hash together with its full message. Files and
+// the line counts are only filled in by CommitsWithFiles.
+type CommitMessage struct {
+	Hash    string
+	Message string
+	Files   []string
+	Added   int
+	Deleted int
+}
+
+// CommitMessages returns the messages of the commits selected by the given
+// git log arguments, newest first.
+func CommitMessages(log logger.Logger, args ...string) ([]CommitMessage, error) {
+	log.Debug("Reading commit messages for %v...", args)
+	logArgs := append([]string{"log", "--format=%H%x00%B%x1e"}, args...)
+	cmd := exec.Command("git", logArgs...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not read commits %v: %w", args, err)
+	}
+
+	var commits []CommitMessage
+	for _, record := range strings.Split(string(output), "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		parts := strings.SplitN(record, "\x00", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		commits = append(commits, CommitMessage{Hash: parts[0], Message: strings.TrimSpace(parts[1])})
+	}
+	return commits, nil
+}
+
+// CommitsWithFiles is CommitMessages with the paths each commit touched and
+// how many lines it added and deleted. A rename counts as both paths.
+func CommitsWithFiles(log logger.Logger, args ...string) ([]CommitMessage, error) {
+	log.Debug("Reading commits with files for %v...", args)
+	logArgs := append([]string{"log", "--numstat", "--no-renames", "--format=%x1e%H%x00%B%x00"}, args...)
+	cmd := exec.Command("git", logArgs...)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not read commits %v: %w", args, err)
+	}
+
+	var commits []CommitMessage
+	for _, record := range strings.Split(string(output), "\x1e") {
+		parts := strings.SplitN(record, "\x00", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		c := CommitMessage{Hash: parts[0], Message: strings.TrimSpace(parts[1])}
+		for _, line := range strings.Split(parts[2], "\n") {
+			// added<TAB>deleted<TAB>path; binary files show "-" and add no lines.
+			fields := strings.SplitN(strings.TrimSpace(line), "\t", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			added, _ := strconv.Atoi(fields[0])
+			deleted, _ := strconv.Atoi(fields[1])
+			c.Added += added
+			c.Deleted += deleted
+			c.Files = append(c.Files, fields[2])
+		}
+		commits = append(commits, c)
+	}
+	return commits, nil
+}
+
+// StagedFiles returns the paths of the changes currently staged in the index.
+func StagedFiles() ([]string, error) {
+	output, err := exec.Command("git", "diff", "--cached", "--name-only", "-z").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list staged files: %w", err)
+	}
+	var files []string
+	for _, name := range strings.Split(string(output), "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// ShowHead returns the content of path at HEAD. The error wraps
+// os.ErrNotExist when path does not exist at HEAD.
+func ShowHead(path string) ([]byte, error) {
+	return Show("HEAD", path)
+}
+
+// Show returns the content of path at rev. The error wraps os.ErrNotExist
+// when path does not exist at rev.
+func Show(rev, path string) ([]byte, error) {
+	output, err := exec.Command("git", "show", rev+":"+path).Output()
+	if err != nil {
+		if exec.Command("git", "cat-file", "-e", rev+":"+path).Run() != nil {
+			return nil, fmt.Errorf("%s is not in %s: %w", path, rev, os.ErrNotExist)
+		}
+		return nil, fmt.Errorf("could not read %s at %s: %w", path, rev, err)
+	}
+	return output, nil
+}
+
+// Change is a commit that touched a file.
+type Change struct {
+	Hash    string
+	Parents []string
+	Subject string
+}
+
+// LastChanges returns the most recent commit that touched each of paths,
+// from a single git log over the last depth commits touching any of them.
+// Paths not touched by those commits are left out.
+func LastChanges(paths []string, depth int) (map[string]Change, error) {
+	changes := make(map[string]Change)
+	if len(paths) == 0 {
+		return changes, nil
+	}
+	args := append([]string{"log", "-n", strconv.Itoa(depth), "--no-renames", "--name-only", "--format=%x1e%H%x00%P%x00%s", "--"}, paths...)
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not read the history of the changed files: %w", err)
+	}
+	for _, record := range strings.Split(string(output), "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.SplitN(lines[0], "\x00", 3)
+		if len(fields) < 3 {
+			continue
+		}
+		c := Change{Hash: fields[0], Parents: strings.Fields(fields[1]), Subject: fields[2]}
+		for _, name := range lines[1:] {
+			if _, seen := changes[name]; name != "" && !seen {
+				changes[name] = c
+			}
+		}
+	}
+	return changes, nil
+}
+
+// ShowBatch returns the contents of objects given as "rev:path", read by a
+// single git cat-file. Objects that do not exist are left out.
+func ShowBatch(specs []string) (map[string][]byte, error) {
+	contents := make(map[string][]byte)
+	if len(specs) == 0 {
+		return contents, nil
+	}
+	cmd := exec.Command("git", "cat-file", "--batch")
+	cmd.Stdin = strings.NewReader(strings.Join(specs, "\n") + "\n")
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not read objects: %w", err)
+	}
+	r := bufio.NewReader(bytes.NewReader(output))
+	for _, spec := range specs {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("could not read object %s: %w", spec, err)
+		}
+		if strings.HasSuffix(header, " missing\n") || strings.HasSuffix(header, " ambiguous\n") {
+			continue
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("could not read object %s: unexpected header %q", spec, header)
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("could not read object %s: %w", spec, err)
+		}
+		content := make([]byte, size+1)
+		if _, err := io.ReadFull(r, content); err != nil {
+			return nil, fmt.Errorf("could not read object %s: %w", spec, err)
+		}
+		if fields[1] == "blob" {
+			contents[spec] = content[:size]
+		}
+	}
+	return contents, nil
+}
+
+// HeadFiles returns the paths of the files directly inside dir at HEAD.
+func HeadFiles(dir string) ([]string, error) {
+	output, err := exec.Command("git", "ls-tree", "-z", "--name-only", "HEAD", "--", strings.TrimSuffix(dir, "/")+"/").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list %s at HEAD: %w", dir, err)
+	}
+	var files []string
+	for _, name := range strings.Split(string(output), "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// Head returns the commit hash HEAD points to.
+func Head() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// StateDir returns the directory autocommit keeps per-worktree state in,
+// <git-dir>/autocommit. It may not exist yet; writers create it with
+// MakeStateDir.
+func StateDir() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-dir")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(string(output)), "autocommit"), nil
+}
+
+// SharedStateDir returns the directory autocommit keeps state shared by all
+// worktrees in, <git-common-dir>/autocommit. It may not exist yet; writers
+// create it with MakeStateDir.
+func SharedStateDir() (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--git-common-dir")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git repository: %w", err)
+	}
+	return filepath.Join(strings.TrimSpace(string(output)), "autocommit"), nil
+}
+
+// MakeStateDir creates a state directory returned by StateDir or
+// SharedStateDir, so that a file can be written to it.
+func MakeStateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	return nil
+}
+
+// TopLevel returns the absolute path of the working tree root.
+func TopLevel() (string, error) {
+	output, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", fmt.Errorf("not inside a git working tree: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// IsAncestor reports whether commit is reachable from ref.
+func IsAncestor(commit, ref string) bool {
+	return exec.Command("git", "merge-base", "--is-ancestor", commit, ref).Run() == nil
+}
+
+// ResetTo moves the current branch back to commit, keeping the changes of
+// the undone commits in the working tree.
+func ResetTo(log logger.Logger, commit string) error {
+	log.Debug("Resetting to %s...", commit)
+	cmd := exec.Command("git", "reset", "--mixed", "--quiet", commit)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git reset failed: %s\n%w", string(output), err)
+	}
+	return nil
+}
+
+// ChangedFiles extracts the file paths from `git status --porcelain` output.
+// For renames the new path is returned.
+func ChangedFiles(changes string) []string {
+	var files []string
+	for _, line := range strings.Split(changes, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) < 2 {
+			continue
+		}
+		files = append(files, parts[len(parts)-1])
+	}
+	return files
+}
+
+// UntrackedFiles returns the paths git status reports as untracked in a
+// snapshot of changes.
+func UntrackedFiles(changes string) []string {
+	var files []string
+	for _, line := range strings.Split(changes, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) >= 2 && parts[0] == "??" {
+			files = append(files, parts[len(parts)-1])
+		}
+	}
+	return files
+}
+
+// AddedLine is a line a commit of the working tree would add to a file.
+type AddedLine struct {
+	Number int
+	Text   string
+}
+
+// hunkRe captures the starting line number in the new file of a diff hunk.
+var hunkRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// IsTracked reports whether path is in the index.
+func IsTracked(path string) bool {
+	return exec.Command("git", "ls-files", "--error-unmatch", "--", path).Run() == nil
+}
+
+// ExpandDirs replaces the untracked directories git status lists as "dir/"
+// with the untracked, not ignored files inside them.
+func ExpandDirs(files []string) ([]string, error) {
+	var expanded []string
+	for _, f := range files {
+		if !strings.HasSuffix(f, "/") {
+			expanded = append(expanded, f)
+			continue
+		}
+		output, err := exec.Command("git", "ls-files", "-z", "--others", "--exclude-standard", "--", f).Output()
+		if err != nil {
+			return nil, fmt.Errorf("could not list the files in %s: %w", f, err)
+		}
+		for _, name := range strings.Split(string(output), "\x00") {
+			if name != "" {
+				expanded = append(expanded, name)
+			}
+		}
+	}
+	return expanded, nil
+}
+
+// AddedLines returns the lines the working tree adds to path relative to
+// HEAD. For untracked files every line is added.
+func AddedLines(path string) ([]AddedLine, error) {
+	var added []AddedLine
+
+	if !IsTracked(path) {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("could not read %s: %w", path, err)
+		}
+		for i, line := range strings.Split(string(content), "\n") {
+			added = append(added, AddedLine{Number: i + 1, Text: line})
+		}
+		return added, nil
+	}
+
+	output, err := exec.Command("git", "diff", "--no-color", "--unified=0", "HEAD", "--", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not diff %s: %w", path, err)
+	}
+	return parseAddedLines(string(output)), nil
+}
+
+// StagedAddedLines returns the lines the index adds to path relative to
+// HEAD, i.e. what committing the staged changes would add.
+func StagedAddedLines(path string) ([]AddedLine, error) {
+	output, err := exec.Command("git", "diff", "--cached", "--no-color", "--unified=0", "--", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not diff staged %s: %w", path, err)
+	}
+	return parseAddedLines(string(output)), nil
+}
+
+// parseAddedLines returns the added lines of a zero-context diff of one file.
+func parseAddedLines(diff string) []AddedLine {
+	var added []AddedLine
+	number := 0
+	for _, line := range strings.Split(diff, "\n") {
+		if m := hunkRe.FindStringSubmatch(line); m != nil {
+			number, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "+++") {
+			added = append(added, AddedLine{Number: number, Text: line[1:]})
+			number++
+		}
+	}
+	return added
+}
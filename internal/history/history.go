@@ -4,22 +4,66 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strings"
 
+	"github.com/urstruelysv/autocommit-cli/internal/git"
 	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/runctx"
 )
 
-// LearnData holds the learned scopes and types
+// learnedVersion is the format version of learned.json. Bump it when
+// LearnData changes incompatibly; a cache with another version is relearned.
+const learnedVersion = 3
+
+const (
+	learnedFile = "learned.json"
+	// legacyCacheFile is where older releases kept learned data, at the
+	// root of the working tree.
+	legacyCacheFile = ".autocommit_cache"
+)
+
+// LearnData holds the learned scopes and types, the path-prefix trie of
+// which scopes and types each path was committed under, and how subjects
+// are phrased. LastCommit is the HEAD the data was last brought up to date
+// with.
 type LearnData struct {
-	Scopes map[string]int
-	Types  map[string]int
+	Version    int            `json:"version"`
+	LastCommit string         `json:"last_commit,omitempty"`
+	Scopes     map[string]int `json:"scopes"`
+	Types      map[string]int `json:"types"`
+	Paths      *PathNode      `json:"paths,omitempty"`
+	Style      Style          `json:"style"`
+}
+
+// learnedPath returns the path of the learned data cache, which lives in the
+// git common dir so it is shared by worktrees and never shows up as a change.
+func learnedPath() (string, error) {
+	dir, err := git.SharedStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, learnedFile), nil
 }
 
+// SaveLearnedData writes the learned data cache. It refuses to write when
+// the run context forbids cache writes (CI mode and dry runs).
 func SaveLearnedData(log logger.Logger, data LearnData) error {
+	if err := runctx.Require(runctx.CacheWrite); err != nil {
+		return err
+	}
 	log.Debug("Saving learned data...")
-	cacheFilePath := ".autocommit_cache"
+	cacheFilePath, err := learnedPath()
+	if err != nil {
+		return err
+	}
+	if err := git.MakeStateDir(filepath.Dir(cacheFilePath)); err != nil {
+		return err
+	}
+	data.Version = learnedVersion
 	jsonData, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal learned data: %w", err)
@@ -34,9 +78,20 @@ func SaveLearnedData(log logger.Logger, data LearnData) error {
 	return nil
 }
 
+// LoadLearnedData reads the learned data cache, migrating a legacy
+// .autocommit_cache from the working tree first if there is one.
 func LoadLearnedData(log logger.Logger) (LearnData, error) {
 	log.Debug("Loading learned data...")
-	cacheFilePath := ".autocommit_cache"
+	cacheFilePath, err := learnedPath()
+	if err != nil {
+		return LearnData{}, err
+	}
+	if _, err := os.Stat(cacheFilePath); os.IsNotExist(err) {
+		if data, ok := migrateLegacyCache(log); ok {
+			return data, nil
+		}
+	}
+
 	jsonData, err := ioutil.ReadFile(cacheFilePath)
 	if err != nil {
 		return LearnData{}, fmt.Errorf("failed to read learned data from %s: %w", cacheFilePath, err)
@@ -47,48 +102,79 @@ func LoadLearnedData(log logger.Logger) (LearnData, error) {
 	if err != nil {
 		return LearnData{}, fmt.Errorf("failed to unmarshal learned data from %s: %w", cacheFilePath, err)
 	}
+	if data.Version != learnedVersion {
+		return LearnData{}, fmt.Errorf("learned data in %s has version %d, want %d", cacheFilePath, data.Version, learnedVersion)
+	}
 	log.Debug("Learned data loaded from %s", cacheFilePath)
 	log.Info("Learned data loaded from %s", cacheFilePath)
 	return data, nil
 }
 
-func LearnFromHistory(log logger.Logger) LearnData {
-	log.Debug("Learning from commit history...")
-	log.Info("\n--- Learning from Commit History ---")
-	logCmd := exec.Command("git", "log", "--pretty=format:%s")
-	logOutput, err := logCmd.Output()
+// migrateLegacyCache moves a .autocommit_cache written by an older release
+// into the git directory and deletes it from the working tree. When cache
+// writes are not allowed the legacy data is used as is and left in place.
+// A tracked legacy file is never deleted: the deletion would end up in the
+// next commit.
+func migrateLegacyCache(log logger.Logger) (LearnData, bool) {
+	root, err := git.TopLevel()
 	if err != nil {
-		log.Error("Could not get git log: %v", err)
-		return LearnData{}
+		return LearnData{}, false
+	}
+	legacyPath := filepath.Join(root, legacyCacheFile)
+	jsonData, err := ioutil.ReadFile(legacyPath)
+	if err != nil {
+		return LearnData{}, false
 	}
 
-	scopes := make(map[string]int)
-	types := make(map[string]int)
+	var data LearnData
+	if err := json.Unmarshal(jsonData, &data); err != nil {
+		log.Error("Ignoring unreadable %s: %v", legacyPath, err)
+		return LearnData{}, false
+	}
+	data.Version = learnedVersion
+	if !runctx.Allows(runctx.CacheWrite) {
+		log.Debug("Using legacy learned data from %s without migrating it", legacyPath)
+		return data, true
+	}
 
-	// Regex to find text in parentheses, like (scope)
-	scopeRe := regexp.MustCompile(`\((.*?)\)`)
-	// Regex to find commit type, e.g., "feat", "fix"
-	typeRe := regexp.MustCompile(`^([a-z]+)(?:\(.*\))?:`)
+	if err := SaveLearnedData(log, data); err != nil {
+		log.Error("Could not migrate %s: %v", legacyPath, err)
+		return data, true
+	}
+	if git.IsTracked(legacyPath) {
+		log.Info("Migrated learned data from %s; it is tracked, so it was left in place. Remove it with `git rm %s` if it is no longer needed.", legacyCacheFile, legacyCacheFile)
+		return data, true
+	}
+	if err := os.Remove(legacyPath); err != nil {
+		log.Error("Migrated %s but could not remove it: %v", legacyPath, err)
+	} else {
+		log.Info("Migrated learned data from %s; the old file has been removed", legacyCacheFile)
+	}
+	return data, true
+}
 
-	commitSubjects := strings.Split(strings.TrimSpace(string(logOutput)), "\n")
-	for _, subject := range commitSubjects {
-		// Extract scope
-		scopeMatches := scopeRe.FindStringSubmatch(subject)
-		if len(scopeMatches) > 1 {
-			scopes[scopeMatches[1]]++
-		}
+// Regex to find text in parentheses, like (scope)
+var scopeRe = regexp.MustCompile(`\((.*?)\)`)
 
-		// Extract type
-		typeMatches := typeRe.FindStringSubmatch(subject)
-		if len(typeMatches) > 1 {
-			types[typeMatches[1]]++
-		}
+// Regex to find commit type, e.g., "feat", "fix"
+var typeRe = regexp.MustCompile(`^([a-z]+)(?:\(.*\))?:`)
+
+// LearnFromHistory rebuilds the learned data from the entire history.
+func LearnFromHistory(log logger.Logger) LearnData {
+	log.Debug("Learning from commit history...")
+	log.Info("\n--- Learning from Commit History ---")
+	data := LearnData{Version: learnedVersion, Scopes: make(map[string]int), Types: make(map[string]int), Paths: &PathNode{}}
+	head, _ := git.Head()
+	if _, err := learnCommits(&data, "HEAD"); err != nil {
+		log.Error("Could not get git log: %v", err)
+		return LearnData{}
 	}
+	data.LastCommit = head
 
-	if len(scopes) > 0 {
+	if len(data.Scopes) > 0 {
 		log.Debug("Found potential scopes.")
 		log.Info("Found potential scopes:")
-		for scope, count := range scopes {
+		for scope, count := range data.Scopes {
 			log.Info("- %s (%d)", scope, count)
 		}
 	} else {
@@ -96,10 +182,10 @@ func LearnFromHistory(log logger.Logger) LearnData {
 		log.Info("No conventional commit scopes found in history.")
 	}
 
-	if len(types) > 0 {
+	if len(data.Types) > 0 {
 		log.Debug("Found potential types.")
 		log.Info("Found potential types:")
-		for t, count := range types {
+		for t, count := range data.Types {
 			log.Info("- %s (%d)", t, count)
 		}
 	} else {
@@ -107,5 +193,81 @@ func LearnFromHistory(log logger.Logger) LearnData {
 		log.Info("No conventional commit types found in history.")
 	}
 
-	return LearnData{Scopes: scopes, Types: types}
+	return data
+}
+
+// UpdateFromHistory brings data up to date with HEAD by learning only from
+// the commits made since data.LastCommit. If there is no last commit, or it
+// is no longer an ancestor of HEAD because history was rewritten, the data
+// is rebuilt from scratch. It reports whether data changed.
+func UpdateFromHistory(log logger.Logger, data LearnData) (LearnData, bool) {
+	head, err := git.Head()
+	if err != nil || data.LastCommit == head {
+		return data, false
+	}
+	if data.LastCommit == "" || !git.IsAncestor(data.LastCommit, head) {
+		log.Debug("Learned data is not based on the current history; rebuilding it")
+		return LearnFromHistory(log), true
+	}
+
+	if data.Scopes == nil {
+		data.Scopes = make(map[string]int)
+	}
+	if data.Types == nil {
+		data.Types = make(map[string]int)
+	}
+	if data.Paths == nil {
+		data.Paths = &PathNode{}
+	}
+	n, err := learnCommits(&data, data.LastCommit+"..HEAD")
+	if err != nil {
+		log.Error("Could not get git log: %v", err)
+		return data, false
+	}
+	log.Debug("Learned from %d new commit(s) since %s", n, data.LastCommit)
+	data.LastCommit = head
+	return data, true
+}
+
+// learnCommits counts the types, scopes and phrasing of the commit subjects
+// in revRange into data, records the files each commit touched in the path
+// trie, and returns how many commits it read.
+func learnCommits(data *LearnData, revRange string) (int, error) {
+	logOutput, err := exec.Command("git", "log", "--name-only", "--pretty=format:%x1e%s", revRange).Output()
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for _, record := range strings.Split(string(logOutput), "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		subject := lines[0]
+		if subject == "" {
+			continue
+		}
+		n++
+		data.Style.Observe(subject)
+
+		commitType, scope := "", ""
+		// Extract scope
+		scopeMatches := scopeRe.FindStringSubmatch(subject)
+		if len(scopeMatches) > 1 {
+			scope = scopeMatches[1]
+			data.Scopes[scope]++
+		}
+
+		// Extract type
+		typeMatches := typeRe.FindStringSubmatch(subject)
+		if len(typeMatches) > 1 {
+			commitType = typeMatches[1]
+			data.Types[commitType]++
+		}
+
+		for _, file := range lines[1:] {
+			if file = strings.TrimSpace(file); file != "" {
+				data.Paths.Add(file, commitType, scope)
+			}
+		}
+	}
+	return n, nil
 }
@@ -0,0 +1,101 @@
+package history
+
+import "strings"
+
+// minPathEvidence is how many past commits must have touched a path under
+// some scope before its mapping is trusted.
+const minPathEvidence = 2
+
+// PathNode is a node of the path-prefix trie learned from history. Each
+// node counts the scopes and types of the commits that touched files at or
+// below its path, once per file, so deeper nodes carry more specific but
+// lighter evidence.
+type PathNode struct {
+	Scopes   map[string]int       `json:"scopes,omitempty"`
+	Types    map[string]int       `json:"types,omitempty"`
+	Children map[string]*PathNode `json:"children,omitempty"`
+}
+
+// Add records that a commit of the given type and scope touched path.
+func (n *PathNode) Add(path, commitType, scope string) {
+	node := n
+	node.count(commitType, scope)
+	for _, part := range strings.Split(path, "/") {
+		if part == "" {
+			continue
+		}
+		if node.Children == nil {
+			node.Children = make(map[string]*PathNode)
+		}
+		child, ok := node.Children[part]
+		if !ok {
+			child = &PathNode{}
+			node.Children[part] = child
+		}
+		child.count(commitType, scope)
+		node = child
+	}
+}
+
+func (n *PathNode) count(commitType, scope string) {
+	if scope != "" {
+		if n.Scopes == nil {
+			n.Scopes = make(map[string]int)
+		}
+		n.Scopes[scope]++
+	}
+	if commitType != "" {
+		if n.Types == nil {
+			n.Types = make(map[string]int)
+		}
+		n.Types[commitType]++
+	}
+}
+
+// PredictScope returns the scope most often used for path, taken from the
+// deepest prefix of path with enough evidence where one scope holds two
+// thirds of the commits, and the share of commits at that node that used it.
+func (n *PathNode) PredictScope(path string) (string, float64) {
+	return n.predict(path, func(node *PathNode) map[string]int { return node.Scopes })
+}
+
+// PredictType is PredictScope for commit types.
+func (n *PathNode) PredictType(path string) (string, float64) {
+	return n.predict(path, func(node *PathNode) map[string]int { return node.Types })
+}
+
+func (n *PathNode) predict(path string, counts func(*PathNode) map[string]int) (string, float64) {
+	if n == nil {
+		return "", 0
+	}
+	best, share := "", 0.0
+	node := n
+	for _, part := range strings.Split(path, "/") {
+		child, ok := node.Children[part]
+		if !ok {
+			break
+		}
+		node = child
+		if name, s, ok := majority(counts(node)); ok {
+			best, share = name, s
+		}
+	}
+	return best, share
+}
+
+// majority returns the key holding at least two thirds of counts, if the
+// counts add up to at least minPathEvidence. Broad directories mix scopes,
+// so a plain majority is not enough.
+func majority(counts map[string]int) (string, float64, bool) {
+	total, best, bestCount := 0, "", 0
+	for name, c := range counts {
+		total += c
+		if c > bestCount || (c == bestCount && name < best) {
+			best, bestCount = name, c
+		}
+	}
+	if total < minPathEvidence || bestCount*3 < total*2 {
+		return "", 0, false
+	}
+	return best, float64(bestCount) / float64(total), true
+}
@@ -0,0 +1,293 @@
+package history
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+)
+
+// minStyleSubjects is how many conventional subjects must have been seen
+// before the learned style is used.
+const minStyleSubjects = 5
+
+// Moods a subject can be phrased in, judged by its first word.
+const (
+	Imperative  = "imperative"   // "add support for X"
+	PastTense   = "past"         // "added support for X"
+	ThirdPerson = "third-person" // "adds support for X"
+)
+
+// knownVerbs are the base forms recognised when reducing an inflected first
+// word back to the verb it came from.
+var knownVerbs = map[string]bool{
+	"add": true, "allow": true, "avoid": true, "bump": true, "change": true,
+	"clean": true, "correct": true, "cover": true, "create": true, "delete": true,
+	"deprecate": true, "disable": true, "document": true, "drop": true, "enable": true,
+	"ensure": true, "extract": true, "fix": true, "format": true, "handle": true,
+	"implement": true, "improve": true, "introduce": true, "make": true, "merge": true,
+	"move": true, "optimize": true, "prevent": true, "reduce": true, "refactor": true,
+	"release": true, "remove": true, "rename": true, "replace": true, "resolve": true,
+	"revert": true, "rewrite": true, "simplify": true, "speed": true, "split": true,
+	"support": true, "test": true, "tidy": true, "update": true, "upgrade": true,
+	"use": true,
+}
+
+// irregularPast maps verbs whose past tense is not formed with "-ed" or
+// needs a doubled consonant.
+var irregularPast = map[string]string{
+	"drop": "dropped", "format": "formatted", "make": "made", "rewrite": "rewrote", "speed": "sped",
+	"split": "split",
+}
+
+// Style holds statistics about how the subjects in history are phrased.
+// All fields are counts, so learning from new commits only adds to them.
+type Style struct {
+	Subjects    int                       `json:"subjects"`
+	Moods       map[string]int            `json:"moods,omitempty"`
+	Capitalised int                       `json:"capitalised"`
+	FullStop    int                       `json:"full_stop"`
+	TotalLength int                       `json:"total_length"`
+	Verbs       map[string]map[string]int `json:"verbs,omitempty"`
+}
+
+// Observe adds a commit subject to the statistics. Only the description
+// after the conventional `type(scope): ` prefix is looked at; subjects
+// without one are ignored.
+func (s *Style) Observe(subject string) {
+	m := typeRe.FindStringSubmatch(subject)
+	if m == nil {
+		return
+	}
+	description := strings.TrimSpace(subject[len(m[0]):])
+	if description == "" {
+		return
+	}
+	s.Subjects++
+	s.TotalLength += len(description)
+	if unicode.IsUpper([]rune(description)[0]) {
+		s.Capitalised++
+	}
+	if strings.HasSuffix(description, ".") {
+		s.FullStop++
+	}
+
+	word := strings.ToLower(strings.Trim(strings.Fields(description)[0], ".,:;"))
+	verb, mood := baseForm(word)
+	if s.Moods == nil {
+		s.Moods = make(map[string]int)
+	}
+	s.Moods[mood]++
+	if verb == "" {
+		return
+	}
+	if s.Verbs == nil {
+		s.Verbs = make(map[string]map[string]int)
+	}
+	if s.Verbs[m[1]] == nil {
+		s.Verbs[m[1]] = make(map[string]int)
+	}
+	s.Verbs[m[1]][verb]++
+}
+
+// Known reports whether enough subjects have been seen to trust the style.
+func (s Style) Known() bool {
+	return s.Subjects >= minStyleSubjects
+}
+
+// Mood returns the mood most subjects are phrased in.
+func (s Style) Mood() string {
+	mood, best := Imperative, 0
+	for _, m := range []string{Imperative, PastTense, ThirdPerson} {
+		if s.Moods[m] > best {
+			mood, best = m, s.Moods[m]
+		}
+	}
+	return mood
+}
+
+// Capitalise reports whether most subjects start with a capital letter.
+func (s Style) Capitalise() bool {
+	return s.Capitalised*2 > s.Subjects
+}
+
+// EndWithFullStop reports whether most subjects end with a full stop.
+func (s Style) EndWithFullStop() bool {
+	return s.FullStop*2 > s.Subjects
+}
+
+// AverageLength returns the typical length of a subject description.
+func (s Style) AverageLength() int {
+	if s.Subjects == 0 {
+		return 0
+	}
+	return s.TotalLength / s.Subjects
+}
+
+// TopVerbs returns up to n verbs most often used for commitType, most used
+// first.
+func (s Style) TopVerbs(commitType string, n int) []string {
+	counts := s.Verbs[commitType]
+	verbs := make([]string, 0, len(counts))
+	for v := range counts {
+		verbs = append(verbs, v)
+	}
+	sort.Slice(verbs, func(i, j int) bool {
+		if counts[verbs[i]] != counts[verbs[j]] {
+			return counts[verbs[i]] > counts[verbs[j]]
+		}
+		return verbs[i] < verbs[j]
+	})
+	if len(verbs) > n {
+		verbs = verbs[:n]
+	}
+	return verbs
+}
+
+// Phrase rewrites a rule-based description, which starts with an imperative
+// verb, to follow the learned style: the type's most common verb, the usual
+// mood, capitalisation and trailing full stop. Capitalisation and the full
+// stop are only applied when rules allow them. It returns description
+// unchanged until the style is known.
+func (s Style) Phrase(rules config.CommitRules, commitType, description string) string {
+	if !s.Known() || description == "" {
+		return description
+	}
+	words := strings.Fields(description)
+	if knownVerbs[words[0]] {
+		if top := s.TopVerbs(commitType, 1); len(top) == 1 && s.Verbs[commitType][top[0]] >= 2 {
+			words[0] = top[0]
+		}
+		words[0] = inflect(words[0], s.Mood())
+	}
+	phrased := strings.Join(words, " ")
+	if capitalised := strings.ToUpper(phrased[:1]) + phrased[1:]; s.Capitalise() && lint.SatisfiesCase(capitalised, rules.SubjectCase) {
+		phrased = capitalised
+	}
+	if s.EndWithFullStop() && fullStopAllowed(rules) {
+		phrased += "."
+	}
+	return phrased
+}
+
+// Guidance describes the learned style as instructions for the AI prompt,
+// or returns "" until the style is known. Traits the rules forbid, such as
+// a capitalised subject under the default subject-case rule, are left out.
+func (s Style) Guidance(rules config.CommitRules) string {
+	if !s.Known() {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Match the style of this repository's commit history:\n")
+	switch s.Mood() {
+	case PastTense:
+		b.WriteString("- Write the subject in the past tense (\"added\", not \"add\").\n")
+	case ThirdPerson:
+		b.WriteString("- Write the subject in the third person (\"adds\", not \"add\").\n")
+	default:
+		b.WriteString("- Write the subject in the imperative mood (\"add\", not \"added\").\n")
+	}
+	switch {
+	case s.Capitalise() && lint.SatisfiesCase("Add support", rules.SubjectCase):
+		b.WriteString("- Start the subject with a capital letter.\n")
+	case !s.Capitalise() && lint.SatisfiesCase("add support", rules.SubjectCase):
+		b.WriteString("- Start the subject with a lowercase letter.\n")
+	}
+	switch {
+	case s.EndWithFullStop() && fullStopAllowed(rules):
+		b.WriteString("- End the subject with a full stop.\n")
+	case !s.EndWithFullStop():
+		b.WriteString("- Do not end the subject with a full stop.\n")
+	}
+	fmt.Fprintf(&b, "- Keep the subject around %d characters.\n", s.AverageLength())
+
+	types := make([]string, 0, len(s.Verbs))
+	for t := range s.Verbs {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(&b, "- Common verbs for %s: %s.\n", t, strings.Join(s.TopVerbs(t, 3), ", "))
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// fullStopAllowed reports whether rules let a subject end with a full stop.
+func fullStopAllowed(rules config.CommitRules) bool {
+	return rules.SubjectFullStop == "" || !strings.HasSuffix(".", rules.SubjectFullStop)
+}
+
+// baseForm reduces the first word of a subject to a known verb and reports
+// the mood it was written in. Words that are not recognised verbs return an
+// empty verb; they are assumed to be imperative.
+func baseForm(word string) (string, string) {
+	if knownVerbs[word] {
+		return word, Imperative
+	}
+	candidates := []struct {
+		suffix, replacement, mood string
+	}{
+		{"ied", "y", PastTense},
+		{"ed", "e", PastTense},
+		{"ed", "", PastTense},
+		{"d", "", PastTense},
+		{"ies", "y", ThirdPerson},
+		{"es", "", ThirdPerson},
+		{"s", "", ThirdPerson},
+	}
+	for _, c := range candidates {
+		if strings.HasSuffix(word, c.suffix) {
+			if base := strings.TrimSuffix(word, c.suffix) + c.replacement; knownVerbs[base] {
+				return base, c.mood
+			}
+		}
+	}
+	for base, past := range irregularPast {
+		if word == past {
+			return base, PastTense
+		}
+	}
+	switch {
+	case strings.HasSuffix(word, "ed"):
+		return "", PastTense
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return "", ThirdPerson
+	}
+	return "", Imperative
+}
+
+// inflect puts a base-form verb into mood.
+func inflect(verb, mood string) string {
+	switch mood {
+	case PastTense:
+		if past, ok := irregularPast[verb]; ok {
+			return past
+		}
+		switch {
+		case strings.HasSuffix(verb, "e"):
+			return verb + "d"
+		case consonantY(verb):
+			return verb[:len(verb)-1] + "ied"
+		}
+		return verb + "ed"
+	case ThirdPerson:
+		switch {
+		case consonantY(verb):
+			return verb[:len(verb)-1] + "ies"
+		case strings.HasSuffix(verb, "s"), strings.HasSuffix(verb, "x"), strings.HasSuffix(verb, "z"),
+			strings.HasSuffix(verb, "ch"), strings.HasSuffix(verb, "sh"):
+			return verb + "es"
+		}
+		return verb + "s"
+	}
+	return verb
+}
+
+// consonantY reports whether word ends in a "y" after a consonant, as in
+// "tidy", whose inflections replace the "y".
+func consonantY(word string) bool {
+	return len(word) > 1 && strings.HasSuffix(word, "y") && !strings.ContainsAny(word[len(word)-2:len(word)-1], "aeiou")
+}
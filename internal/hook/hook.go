@@ -0,0 +1,144 @@
+package hook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/ai"
+	"github.com/urstruelysv/autocommit-cli/internal/classify"
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/history"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/prompt"
+	"github.com/urstruelysv/autocommit-cli/internal/secrets"
+)
+
+// marker identifies hook scripts written by autocommit so they can be
+// replaced safely without clobbering hooks installed by other tools.
+const marker = "# installed by autocommit-cli"
+
+const (
+	// PrepareCommitMsg is the name of the hook that fills in generated messages.
+	PrepareCommitMsg = "prepare-commit-msg"
+	// CommitMsg is the name of the hook that lints the final message.
+	CommitMsg = "commit-msg"
+)
+
+// Install writes a hook script that calls back into the autocommit binary.
+// An existing hook that was not written by autocommit is only replaced when
+// force is set.
+func Install(log logger.Logger, name string, force bool) (string, error) {
+	if name != PrepareCommitMsg && name != CommitMsg {
+		return "", fmt.Errorf("unsupported hook %q", name)
+	}
+
+	hooksDir, err := git.HooksDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(hooksDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create hooks directory %s: %w", hooksDir, err)
+	}
+
+	hookPath := filepath.Join(hooksDir, name)
+	if existing, err := ioutil.ReadFile(hookPath); err == nil {
+		if !strings.Contains(string(existing), marker) && !force {
+			return "", fmt.Errorf("%s already exists and was not installed by autocommit; use --force to replace it", hookPath)
+		}
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("could not resolve autocommit binary path: %w", err)
+	}
+
+	script := fmt.Sprintf("#!/bin/sh\n%s\nexec %q hook %s \"$@\"\n", marker, binary, name)
+	if err := ioutil.WriteFile(hookPath, []byte(script), 0755); err != nil {
+		return "", fmt.Errorf("failed to write hook %s: %w", hookPath, err)
+	}
+	log.Debug("Installed %s hook at %s", name, hookPath)
+	return hookPath, nil
+}
+
+// RunPrepareCommitMsg implements the prepare-commit-msg hook. Git passes the
+// message file path and, optionally, the source of the message. Messages that
+// already have a source (-m, -F, merge, squash, amend, template) are left
+// untouched; otherwise, unless the staged changes hold a possible secret, a
+// message is generated from the staged diff and written above the comment
+// block git placed in the file.
+func RunPrepareCommitMsg(log logger.Logger, cfg config.Config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("prepare-commit-msg: missing message file argument")
+	}
+	msgFile := args[0]
+	if len(args) > 1 && args[1] != "" {
+		log.Debug("Message source is %q; leaving message untouched.", args[1])
+		return nil
+	}
+
+	diff, err := git.StagedDiff(log)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(diff) == "" {
+		log.Debug("Nothing staged; leaving message untouched.")
+		return nil
+	}
+
+	// The staged diff is sent to the AI, so it must not carry secrets.
+	files, err := git.StagedFiles()
+	if err != nil {
+		return err
+	}
+	var findings []secrets.Finding
+	for _, file := range files {
+		added, err := git.StagedAddedLines(file)
+		if err != nil {
+			return fmt.Errorf("secret scan failed: %w", err)
+		}
+		findings = append(findings, secrets.Scan(file, added)...)
+	}
+	if len(findings) > 0 {
+		for _, f := range findings {
+			log.Error("Possible secret: %s", f)
+		}
+		return fmt.Errorf("secret scan found %d possible secret(s) in the staged changes; not sending them to the AI", len(findings))
+	}
+
+	if os.Getenv("GEMINI_API_KEY") == "" {
+		return fmt.Errorf("GEMINI_API_KEY not set")
+	}
+	rules, err := config.LoadCommitRules(cfg)
+	if err != nil {
+		return err
+	}
+	learned, _ := history.LoadLearnedData(log)
+	// Generated, vendored and lockfile diffs are noise to the AI.
+	files, derived := classify.SeparateDerived(files)
+	if len(derived) > 0 {
+		if diff, err = git.StagedDiff(log, derived...); err != nil {
+			return err
+		}
+	}
+	examples := prompt.Examples(log, prompt.QueryFor(learned, files), cfg.FewShotExamples)
+	message, err := ai.GenerateAICommitMessage(log, prompt.Build(diff, learned.Style.Guidance(rules), examples, derived))
+	if err != nil {
+		return err
+	}
+	message = lint.Enforce(log, rules, message)
+
+	existing, err := ioutil.ReadFile(msgFile)
+	if err != nil {
+		return fmt.Errorf("failed to read message file %s: %w", msgFile, err)
+	}
+	content := message + "\n" + string(existing)
+	if err := ioutil.WriteFile(msgFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write message file %s: %w", msgFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package lint
+
+import (
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+)
+
+// BreakingFooter is the footer token that lists breaking changes.
+const BreakingFooter = "BREAKING CHANGE"
+
+// MarkBreaking marks a message as a breaking change: it adds `!` to the
+// header and a BREAKING CHANGE footer listing changes, unless the message
+// already has one. The footer is wrapped to the rules' footer-max-line-length.
+// Messages without a conventional header are returned unchanged.
+func MarkBreaking(rules config.CommitRules, message string, changes []string) string {
+	if len(changes) == 0 {
+		return message
+	}
+	lines := strings.SplitN(strings.TrimSpace(message), "\n", 2)
+	h, ok := ParseHeader(lines[0])
+	if !ok {
+		return message
+	}
+	h.Breaking = true
+	body := ""
+	if len(lines) > 1 {
+		body = strings.TrimSpace(lines[1])
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, BreakingFooter+": ") || strings.HasPrefix(line, "BREAKING-CHANGE: ") {
+			return h.String() + "\n\n" + body
+		}
+	}
+	footer := BreakingFooter + ": " + strings.Join(changes, "; ")
+	if rules.FooterMaxLineLength > 0 {
+		footer = wrapLines(footer, rules.FooterMaxLineLength)
+	}
+	if body == "" {
+		return h.String() + "\n\n" + footer
+	}
+	return h.String() + "\n\n" + body + "\n\n" + footer
+}
@@ -0,0 +1,89 @@
+package lint
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+)
+
+var (
+	camelRe  = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+	pascalRe = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	kebabRe  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	snakeRe  = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+)
+
+// MatchesCase reports whether s is written in the named commitlint case.
+// Unknown case names match everything.
+func MatchesCase(s, name string) bool {
+	switch name {
+	case "lower-case", "lowercase":
+		return s == strings.ToLower(s)
+	case "upper-case", "uppercase":
+		return s == strings.ToUpper(s)
+	case "camel-case":
+		return camelRe.MatchString(s)
+	case "pascal-case":
+		return pascalRe.MatchString(s)
+	case "kebab-case":
+		return kebabRe.MatchString(s)
+	case "snake-case":
+		return snakeRe.MatchString(s)
+	case "sentence-case", "sentencecase":
+		first, _ := utf8.DecodeRuneInString(s)
+		return !unicode.IsLower(first)
+	case "start-case":
+		for _, word := range strings.Fields(s) {
+			first, _ := utf8.DecodeRuneInString(word)
+			if unicode.IsLower(first) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// SatisfiesCase reports whether s meets a case rule. An empty rule is
+// always satisfied.
+func SatisfiesCase(s string, rule config.CaseRule) bool {
+	if len(rule.Cases) == 0 {
+		return true
+	}
+	for _, name := range rule.Cases {
+		if MatchesCase(s, name) {
+			return !rule.Never
+		}
+	}
+	return rule.Never
+}
+
+// applyCase rewrites s so that it satisfies rule, where that can be done
+// without guessing: forbidden cases are fixed by lower-casing the first
+// letter, then the whole string; required cases are applied directly.
+func applyCase(s string, rule config.CaseRule) string {
+	if SatisfiesCase(s, rule) || s == "" {
+		return s
+	}
+	if rule.Never {
+		first, size := utf8.DecodeRuneInString(s)
+		s = string(unicode.ToLower(first)) + s[size:]
+		if !SatisfiesCase(s, rule) {
+			s = strings.ToLower(s)
+		}
+		return s
+	}
+	switch rule.Cases[0] {
+	case "lower-case", "lowercase":
+		return strings.ToLower(s)
+	case "upper-case", "uppercase":
+		return strings.ToUpper(s)
+	case "sentence-case", "sentencecase":
+		first, size := utf8.DecodeRuneInString(s)
+		return string(unicode.ToUpper(first)) + s[size:]
+	}
+	return s
+}
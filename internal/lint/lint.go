@@ -0,0 +1,263 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+)
+
+// headerRe matches a Conventional Commits header: type(scope)!: subject
+var headerRe = regexp.MustCompile(`^([A-Za-z]+)(?:\(([^()\r\n]*)\))?(!)?: (.*)$`)
+
+// Header is the parsed first line of a conventional commit message.
+type Header struct {
+	Type     string
+	Scope    string
+	Breaking bool
+	Subject  string
+}
+
+// ParseHeader parses the first line of a commit message. The boolean result
+// reports whether the line follows the conventional commit grammar.
+func ParseHeader(line string) (Header, bool) {
+	m := headerRe.FindStringSubmatch(line)
+	if m == nil {
+		return Header{}, false
+	}
+	return Header{Type: m[1], Scope: m[2], Breaking: m[3] == "!", Subject: m[4]}, true
+}
+
+// String renders the header back into its conventional form.
+func (h Header) String() string {
+	var b strings.Builder
+	b.WriteString(h.Type)
+	if h.Scope != "" {
+		b.WriteString("(" + h.Scope + ")")
+	}
+	if h.Breaking {
+		b.WriteString("!")
+	}
+	b.WriteString(": " + h.Subject)
+	return b.String()
+}
+
+// Violation describes a single rule a commit message breaks. Warnings come
+// from rules configured at warning level and do not make a message invalid.
+type Violation struct {
+	Rule    string
+	Message string
+	Warning bool
+}
+
+func (v Violation) String() string {
+	if v.Warning {
+		return fmt.Sprintf("[%s] (warning) %s", v.Rule, v.Message)
+	}
+	return fmt.Sprintf("[%s] %s", v.Rule, v.Message)
+}
+
+// Errors returns the violations that are not warnings.
+func Errors(violations []Violation) []Violation {
+	var errs []Violation
+	for _, v := range violations {
+		if !v.Warning {
+			errs = append(errs, v)
+		}
+	}
+	return errs
+}
+
+// CleanMessage strips what git itself strips before recording a message:
+// comment lines, everything below the scissors line, and surrounding blank lines.
+func CleanMessage(raw string) string {
+	var kept []string
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.HasPrefix(line, "# ------------------------ >8 ------------------------") {
+			break
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		kept = append(kept, strings.TrimRight(line, " \t\r"))
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// ignoredRes are commitlint's default ignores: messages git and hosting
+// services write themselves, and fixups meant to be squashed away.
+var ignoredRes = []*regexp.Regexp{
+	regexp.MustCompile(`^((Merge pull request)|(Merge (.*?) into (.*?)|(Merge branch (.*?)))(?:\r?\n)*$)`),
+	regexp.MustCompile(`^(Merge tag (.*?))(?:\r?\n)*$`),
+	regexp.MustCompile(`^(R|r)evert (.*)`),
+	regexp.MustCompile(`^(amend|fixup|squash)!`),
+	regexp.MustCompile(`^(Merged (.*?)(in|into) (.*)|Merged PR (.*): (.*))`),
+	regexp.MustCompile(`^Merge remote-tracking branch(\s*)(.*)`),
+	regexp.MustCompile(`^Automatic merge(.*)`),
+	regexp.MustCompile(`^Auto-merged (.*?) into (.*)`),
+}
+
+// Ignored reports whether a message is one commitlint skips by default,
+// such as "Merge branch ...", `Revert "..."`, "fixup! ..." or "squash! ...".
+func Ignored(message string) bool {
+	header := strings.SplitN(strings.TrimSpace(message), "\n", 2)[0]
+	for _, re := range ignoredRes {
+		if re.MatchString(header) {
+			return true
+		}
+	}
+	return false
+}
+
+// Message checks a commit message against the compiled rules and returns
+// every violation found, in a stable order.
+func Message(rules config.CommitRules, message string) []Violation {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{
+			Rule:    rule,
+			Message: fmt.Sprintf(format, args...),
+			Warning: rules.Warnings[rule],
+		})
+	}
+
+	message = strings.TrimSpace(message)
+	lines := strings.Split(message, "\n")
+	header := lines[0]
+
+	if header == "" {
+		add("header-empty", "commit message is empty")
+		return violations
+	}
+
+	if pattern := compiledRegex(rules.CommitMessageRegex); pattern != nil && !pattern.MatchString(header) {
+		add("header-pattern", "header %q does not match %s", header, rules.CommitMessageRegex)
+	}
+
+	n := utf8.RuneCountInString(header)
+	if rules.HeaderMaxLength > 0 && n > rules.HeaderMaxLength {
+		add("header-max-length", "header is %d characters, maximum is %d", n, rules.HeaderMaxLength)
+	}
+	if rules.HeaderMinLength > 0 && n < rules.HeaderMinLength {
+		add("header-min-length", "header is %d characters, minimum is %d", n, rules.HeaderMinLength)
+	}
+
+	h, ok := ParseHeader(header)
+	if !ok {
+		add("header-format", "header %q is not in the form \"type(scope): subject\"", header)
+		return violations
+	}
+
+	if rules.TypeCase != "" && !MatchesCase(h.Type, rules.TypeCase) {
+		add("type-case", "type %q must be %s", h.Type, rules.TypeCase)
+	}
+	if len(rules.Types) > 0 && !contains(rules.Types, h.Type) {
+		add("type-enum", "type %q is not one of [%s]", h.Type, strings.Join(rules.Types, ", "))
+	}
+
+	if h.Scope == "" && rules.ScopeRequired {
+		add("scope-empty", "scope is required")
+	}
+	if h.Scope != "" && len(rules.Scopes) > 0 {
+		for _, scope := range splitScopes(h.Scope) {
+			if !contains(rules.Scopes, scope) {
+				add("scope-enum", "scope %q is not one of [%s]", scope, strings.Join(rules.Scopes, ", "))
+			}
+		}
+	}
+
+	subject := strings.TrimSpace(h.Subject)
+	if subject == "" {
+		add("subject-empty", "subject is empty")
+		return violations
+	}
+	if n := utf8.RuneCountInString(subject); rules.SubjectMaxLength > 0 && n > rules.SubjectMaxLength {
+		add("subject-max-length", "subject is %d characters, maximum is %d", n, rules.SubjectMaxLength)
+	}
+	if !SatisfiesCase(subject, rules.SubjectCase) {
+		verb := "must be"
+		if rules.SubjectCase.Never {
+			verb = "must not be"
+		}
+		add("subject-case", "subject %q %s %s", subject, verb, strings.Join(rules.SubjectCase.Cases, ", "))
+	}
+	if rules.SubjectFullStop != "" && strings.HasSuffix(subject, rules.SubjectFullStop) {
+		add("subject-full-stop", "subject must not end with %q", rules.SubjectFullStop)
+	}
+
+	if len(lines) > 1 {
+		if rules.BodyLeadingBlank && strings.TrimSpace(lines[1]) != "" {
+			add("body-leading-blank", "body must be separated from the header by a blank line")
+		}
+		body, footer := splitBody(lines[1:])
+		for i, line := range body {
+			if n := utf8.RuneCountInString(line); rules.BodyMaxLineLength > 0 && n > rules.BodyMaxLineLength {
+				add("body-max-line-length", "body line %d is %d characters, maximum is %d", i+1, n, rules.BodyMaxLineLength)
+			}
+		}
+		for i, line := range footer {
+			if n := utf8.RuneCountInString(line); rules.FooterMaxLineLength > 0 && n > rules.FooterMaxLineLength {
+				add("footer-max-line-length", "footer line %d is %d characters, maximum is %d", i+1, n, rules.FooterMaxLineLength)
+			}
+		}
+	}
+
+	return violations
+}
+
+// footerRe matches a git trailer or a BREAKING CHANGE footer.
+var footerRe = regexp.MustCompile(`^(BREAKING[ -]CHANGE|[A-Za-z][A-Za-z-]*): `)
+
+// splitBody separates the lines below the header into body and footer. The
+// footer is the last paragraph when it starts with a trailer.
+func splitBody(lines []string) (body, footer []string) {
+	last := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			last = i
+		}
+	}
+	if last >= 0 && last+1 < len(lines) && footerRe.MatchString(lines[last+1]) {
+		return lines[:last], lines[last+1:]
+	}
+	return lines, nil
+}
+
+// compiledRegex compiles a rule pattern, accepting the /pattern/ form used in
+// commit guides. Invalid or empty patterns disable the check.
+func compiledRegex(pattern string) *regexp.Regexp {
+	pattern = strings.TrimSpace(pattern)
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+// splitScopes splits multi-scope headers such as "feat(api,cli): ...".
+func splitScopes(scope string) []string {
+	var scopes []string
+	for _, s := range strings.FieldsFunc(scope, func(r rune) bool { return r == ',' || r == '/' }) {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
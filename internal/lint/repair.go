@@ -0,0 +1,162 @@
+package lint
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+// FallbackMessage is committed when a generated message cannot be repaired.
+const FallbackMessage = "chore: automated commit"
+
+// typeAliases maps common non-standard spellings to conventional types.
+var typeAliases = map[string]string{
+	"feature":       "feat",
+	"features":      "feat",
+	"bugfix":        "fix",
+	"hotfix":        "fix",
+	"fixes":         "fix",
+	"doc":           "docs",
+	"documentation": "docs",
+	"tests":         "test",
+	"testing":       "test",
+	"refactoring":   "refactor",
+	"performance":   "perf",
+	"chores":        "chore",
+	"styles":        "style",
+}
+
+// Enforce validates a generated message and returns the message to commit.
+// A message that breaks the rules gets exactly one deterministic repair; if
+// the repaired message is still invalid, FallbackMessage is used. Enforce
+// never asks for a new message, so a run calls the AI at most once.
+func Enforce(log logger.Logger, rules config.CommitRules, message string) string {
+	violations := Errors(Message(rules, message))
+	if len(violations) == 0 {
+		return strings.TrimSpace(message)
+	}
+	for _, v := range violations {
+		log.Debug("Generated message violates %s", v)
+	}
+
+	repaired := Repair(rules, message)
+	if len(Errors(Message(rules, repaired))) == 0 {
+		log.Info("Repaired commit message: %q", strings.SplitN(repaired, "\n", 2)[0])
+		return repaired
+	}
+
+	log.Error("Generated commit message could not be repaired; using %q", FallbackMessage)
+	return FallbackMessage
+}
+
+// Repair applies a fixed sequence of corrections to a message: it strips
+// quoting and code fences, normalises the type, drops scopes the rules do not
+// allow, fixes the subject case and trailing full stop, truncates the header
+// at a word boundary and re-wraps body lines that are too long.
+func Repair(rules config.CommitRules, message string) string {
+	message = stripQuoting(message)
+	lines := strings.SplitN(message, "\n", 2)
+	header := strings.TrimSpace(lines[0])
+	body := ""
+	if len(lines) > 1 {
+		body = strings.TrimSpace(lines[1])
+	}
+
+	h, ok := ParseHeader(header)
+	if !ok {
+		return message
+	}
+
+	h.Type = strings.ToLower(h.Type)
+	if alias, ok := typeAliases[h.Type]; ok && (len(rules.Types) == 0 || contains(rules.Types, alias)) {
+		h.Type = alias
+	}
+
+	if h.Scope != "" && len(rules.Scopes) > 0 {
+		var kept []string
+		for _, scope := range splitScopes(h.Scope) {
+			if contains(rules.Scopes, scope) {
+				kept = append(kept, scope)
+			}
+		}
+		h.Scope = strings.Join(kept, ",")
+	}
+
+	h.Subject = strings.TrimSpace(h.Subject)
+	if rules.SubjectFullStop != "" {
+		h.Subject = strings.TrimSpace(strings.TrimRight(h.Subject, rules.SubjectFullStop))
+	}
+	h.Subject = applyCase(h.Subject, rules.SubjectCase)
+	if rules.SubjectMaxLength > 0 {
+		h.Subject = truncateWords(h.Subject, rules.SubjectMaxLength)
+	}
+
+	header = h.String()
+	if rules.HeaderMaxLength > 0 {
+		header = truncateWords(header, rules.HeaderMaxLength)
+	}
+
+	if body == "" {
+		return header
+	}
+	if rules.BodyMaxLineLength > 0 {
+		body = wrapLines(body, rules.BodyMaxLineLength)
+	}
+	return header + "\n\n" + body
+}
+
+// wrapLines re-wraps every line longer than width at word boundaries. Lines
+// that cannot be broken, such as long URLs, are left as they are.
+func wrapLines(text string, width int) string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if utf8.RuneCountInString(line) <= width {
+			out = append(out, line)
+			continue
+		}
+		current := ""
+		for _, word := range strings.Fields(line) {
+			if current != "" && utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+				out = append(out, current)
+				current = word
+				continue
+			}
+			if current != "" {
+				current += " "
+			}
+			current += word
+		}
+		out = append(out, current)
+	}
+	return strings.Join(out, "\n")
+}
+
+// stripQuoting removes the wrapping that language models often add around a
+// commit message: code fences, backticks and quotes.
+func stripQuoting(message string) string {
+	message = strings.TrimSpace(message)
+	if strings.HasPrefix(message, "```") {
+		message = strings.TrimPrefix(message, "```")
+		if i := strings.Index(message, "\n"); i != -1 {
+			message = message[i+1:]
+		}
+		message = strings.TrimSuffix(strings.TrimSpace(message), "```")
+	}
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(message), "`\"'"))
+}
+
+// truncateWords shortens s to at most max runes, cutting at the last space
+// when there is one past the type prefix.
+func truncateWords(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	cut := string(runes[:max])
+	if i := strings.LastIndex(cut, " "); i > 0 && i > strings.Index(cut, ": ")+1 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,;:-")
+}
@@ -13,18 +13,34 @@ type Logger interface {
 	Error(format string, args ...interface{})
 	Fatal(code int, format string, args ...interface{})
 	Debug(format string, args ...interface{})
+	// Result reports how the run ended and exits with code. It is always
+	// the last line a run logs.
+	Result(code int, result string, format string, args ...interface{})
 }
 
 // HumanReadableLogger implements Logger for human-readable output.
-type HumanReadableLogger struct{}
+type HumanReadableLogger struct {
+	verbose bool
+	quiet   bool
+}
+
+// NewHumanReadableLogger creates a new HumanReadableLogger. Debug messages
+// are only printed when verbose is set.
+func NewHumanReadableLogger(verbose bool) *HumanReadableLogger {
+	return &HumanReadableLogger{verbose: verbose}
+}
 
-// NewHumanReadableLogger creates a new HumanReadableLogger.
-func NewHumanReadableLogger() *HumanReadableLogger {
-	return &HumanReadableLogger{}
+// NewQuietLogger creates a HumanReadableLogger that drops informational
+// and debug messages, for commands whose stdout is machine-readable.
+func NewQuietLogger() *HumanReadableLogger {
+	return &HumanReadableLogger{quiet: true}
 }
 
 // Info prints informational messages to stdout.
 func (l *HumanReadableLogger) Info(format string, args ...interface{}) {
+	if l.quiet {
+		return
+	}
 	fmt.Printf(format+"\n", args...)
 }
 
@@ -41,27 +57,47 @@ func (l *HumanReadableLogger) Fatal(code int, format string, args ...interface{}
 	os.Exit(code)
 }
 
-// Debug prints debug messages (currently to stdout, can be conditional).
+// Debug prints debug messages to stdout in verbose mode.
 func (l *HumanReadableLogger) Debug(format string, args ...interface{}) {
-	// For now, debug messages are just info messages.
-	// This can be made conditional based on a verbose flag later.
+	if !l.verbose {
+		return
+	}
 	fmt.Printf("DEBUG: "+format+"\n", args...)
 }
 
+// Result prints the final message of a run and exits with code. Failures go
+// to stderr; success and "no changes" go to stdout.
+func (l *HumanReadableLogger) Result(code int, result string, format string, args ...interface{}) {
+	if format != "" {
+		if code == 0 || result == "no_changes" {
+			fmt.Printf(format+"\n", args...)
+		} else {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		}
+	}
+	os.Exit(code)
+}
+
 // LogEntry defines the schema for JSON log output.
 type LogEntry struct {
 	Timestamp string `json:"timestamp"`
 	Level     string `json:"level"`
 	Message   string `json:"message"`
 	Code      int    `json:"code,omitempty"` // For fatal errors
+	// ExitCode and Result are only set on the final "result" entry.
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Result   string `json:"result,omitempty"`
 }
 
 // JSONLogger implements Logger for JSON output.
-type JSONLogger struct{}
+type JSONLogger struct {
+	verbose bool
+}
 
-// NewJSONLogger creates a new JSONLogger.
-func NewJSONLogger() *JSONLogger {
-	return &JSONLogger{}
+// NewJSONLogger creates a new JSONLogger. Debug entries are only written
+// when verbose is set.
+func NewJSONLogger(verbose bool) *JSONLogger {
+	return &JSONLogger{verbose: verbose}
 }
 
 func (l *JSONLogger) logJSON(level string, format string, args ...interface{}) {
@@ -106,8 +142,29 @@ func (l *JSONLogger) Fatal(code int, format string, args ...interface{}) {
 	os.Exit(code)
 }
 
-// Debug prints debug messages to stdout in JSON format.
+// Debug prints debug messages to stdout in JSON format in verbose mode.
 func (l *JSONLogger) Debug(format string, args ...interface{}) {
+	if !l.verbose {
+		return
+	}
 	l.logJSON("debug", format, args...)
 }
 
+// Result prints the final entry of a run in JSON format, carrying the exit
+// code and result name, and exits with code.
+func (l *JSONLogger) Result(code int, result string, format string, args ...interface{}) {
+	entry := LogEntry{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Level:     "result",
+		Message:   fmt.Sprintf(format, args...),
+		ExitCode:  &code,
+		Result:    result,
+	}
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to marshal result log entry to JSON: %v - %s\n", err, fmt.Sprintf(format, args...))
+	} else {
+		fmt.Println(string(jsonBytes))
+	}
+	os.Exit(code)
+}
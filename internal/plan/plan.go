@@ -0,0 +1,120 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+)
+
+// CommitPlan is a single commit the run intends to make. Rationale explains
+// how the group and its message were arrived at. Content holds the version
+// to commit for files that are only partly committed, such as a change's
+// formatting split off into its own commit. Confidence and Signals carry
+// the classification's confidence and per-file evidence, for `plan --explain`.
+type CommitPlan struct {
+	Type       string            `json:"type"`
+	Scope      string            `json:"scope,omitempty"`
+	Files      []string          `json:"files"`
+	Content    map[string]string `json:"content,omitempty"`
+	Message    string            `json:"message"`
+	Rationale  []string          `json:"rationale,omitempty"`
+	Confidence float64           `json:"confidence,omitempty"`
+	Signals    []string          `json:"signals,omitempty"`
+}
+
+// Plan is the ordered list of commits computed once per run. Head and
+// Snapshot pin the repository state it was computed against, so a saved
+// plan is never applied to a tree that has changed since.
+type Plan struct {
+	Head     string       `json:"head"`
+	Snapshot string       `json:"snapshot"`
+	Commits  []CommitPlan `json:"commits"`
+}
+
+// LastRun records the commits made by the most recent run so it can be undone.
+type LastRun struct {
+	Before  string `json:"before"`
+	After   string `json:"after"`
+	Commits int    `json:"commits"`
+}
+
+const (
+	planFile    = "plan.json"
+	lastRunFile = "last-run.json"
+)
+
+// Save writes the plan to the repository's autocommit state directory.
+func Save(p Plan) (string, error) {
+	return writeState(planFile, p)
+}
+
+// Load reads the plan saved by `autocommit plan`.
+func Load() (Plan, error) {
+	var p Plan
+	err := readState(planFile, &p)
+	return p, err
+}
+
+// Discard removes a saved plan once it has been applied.
+func Discard() error {
+	dir, err := git.StateDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, planFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove saved plan: %w", err)
+	}
+	return nil
+}
+
+// SaveLastRun records the commits made by a run.
+func SaveLastRun(r LastRun) error {
+	_, err := writeState(lastRunFile, r)
+	return err
+}
+
+// LoadLastRun reads the record written by the most recent run.
+func LoadLastRun() (LastRun, error) {
+	var r LastRun
+	err := readState(lastRunFile, &r)
+	return r, err
+}
+
+func writeState(name string, v interface{}) (string, error) {
+	dir, err := git.StateDir()
+	if err != nil {
+		return "", err
+	}
+	if err := git.MakeStateDir(dir); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name)
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s: %w", name, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+func readState(name string, v interface{}) error {
+	dir, err := git.StateDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package prompt
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/history"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+// searchDepth bounds how many recent commits are searched for examples.
+const searchDepth = 500
+
+// maxExampleLength keeps a single long commit message from crowding out the
+// diff in the prompt. It counts runes.
+const maxExampleLength = 600
+
+// Query describes the change a message is being generated for. Type and
+// Scope are left empty when they are not known yet.
+type Query struct {
+	Files []string
+	Type  string
+	Scope string
+}
+
+// QueryFor builds a query for files, filling in the type and scope the
+// learned path trie predicts when every file agrees on one.
+func QueryFor(learned history.LearnData, files []string) Query {
+	q := Query{Files: files}
+	q.Type = agree(files, learned.Paths.PredictType)
+	q.Scope = agree(files, learned.Paths.PredictScope)
+	return q
+}
+
+func agree(files []string, predict func(string) (string, float64)) string {
+	result := ""
+	for i, file := range files {
+		p, _ := predict(file)
+		if p == "" || (i > 0 && p != result) {
+			return ""
+		}
+		result = p
+	}
+	return result
+}
+
+// Examples returns up to n recent commits most relevant to q, most relevant
+// first. A commit scores for every file it shares with q, less for files in
+// the same directory, and for a matching type or scope. Commits that score
+// nothing are never used.
+func Examples(log logger.Logger, q Query, n int) []git.CommitMessage {
+	if n <= 0 {
+		return nil
+	}
+	commits, err := git.CommitsWithFiles(log, "-n", fmt.Sprint(searchDepth), "--no-merges")
+	if err != nil {
+		log.Debug("No few-shot examples: %v", err)
+		return nil
+	}
+
+	type scored struct {
+		commit git.CommitMessage
+		score  int
+	}
+	var candidates []scored
+	for _, c := range commits {
+		if s := relevance(q, c); s > 0 {
+			candidates = append(candidates, scored{c, s})
+		}
+	}
+	// Stable, so equally relevant commits stay newest first.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	var examples []git.CommitMessage
+	for _, c := range candidates {
+		if len(examples) == n {
+			break
+		}
+		examples = append(examples, c.commit)
+	}
+	return examples
+}
+
+func relevance(q Query, c git.CommitMessage) int {
+	score := 0
+	dirs := make(map[string]bool)
+	for _, f := range q.Files {
+		dirs[path.Dir(f)] = true
+	}
+	for _, f := range c.Files {
+		switch {
+		case contains(q.Files, f):
+			score += 3
+		case dirs[path.Dir(f)]:
+			score++
+		}
+	}
+	if score == 0 {
+		return 0
+	}
+
+	h, ok := lint.ParseHeader(strings.SplitN(c.Message, "\n", 2)[0])
+	if !ok {
+		// Only conventional commits make useful examples.
+		return 0
+	}
+	if q.Type != "" && h.Type == q.Type {
+		score += 2
+	}
+	if q.Scope != "" && h.Scope == q.Scope {
+		score += 2
+	}
+	return score
+}
+
+// Build returns the prompt for a commit message: the instructions, the
+// learned style guidance, the few-shot examples and the diff. omitted
+// lists the generated, vendored and lockfiles left out of the diff; only
+// their names are shown.
+func Build(diff, guidance string, examples []git.CommitMessage, omitted []string) string {
+	var b strings.Builder
+	b.WriteString(`Generate a concise conventional commit message (type: subject) for the following Git diff.
+The commit message should accurately summarize the changes.
+Do not include any explanations or additional text, just the commit message.
+`)
+	if len(examples) == 0 {
+		b.WriteString("\nExample: feat: add new user authentication endpoint\n")
+	} else {
+		b.WriteString("\nThese earlier commits in this repository touched related files; follow their conventions:\n")
+		for _, e := range examples {
+			message := e.Message
+			if runes := []rune(message); len(runes) > maxExampleLength {
+				message = strings.TrimSpace(string(runes[:maxExampleLength])) + "\n[...]"
+			}
+			fmt.Fprintf(&b, "---\n%s\n", message)
+		}
+		b.WriteString("---\n")
+	}
+	if guidance != "" {
+		fmt.Fprintf(&b, "\n%s\n", guidance)
+	}
+	if len(omitted) > 0 {
+		fmt.Fprintf(&b, "\nAlso changed, but generated, vendored or lockfiles whose diff is left out: %s\n", strings.Join(omitted, ", "))
+	}
+	fmt.Fprintf(&b, "\nDiff:\n%s", diff)
+	return b.String()
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
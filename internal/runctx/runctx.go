@@ -0,0 +1,69 @@
+package runctx
+
+import (
+	"sync"
+
+	"github.com/urstruelysv/autocommit-cli/internal/exitcode"
+)
+
+// Operation is a side effect that some run modes forbid.
+type Operation string
+
+const (
+	// CacheWrite is writing learned data to disk.
+	CacheWrite Operation = "writing the learned-data cache"
+	// StdinRead is reading interactive input.
+	StdinRead Operation = "reading from stdin"
+	// Editor is launching $EDITOR.
+	Editor Operation = "launching $EDITOR"
+	// Banner is printing the ANSI welcome banner.
+	Banner Operation = "printing the ANSI banner"
+)
+
+// Context holds the invariants of the current run. It is set once, when the
+// run mode is resolved, and checked at every I/O boundary that could break
+// it. In CI mode none of the operations above are allowed; a dry run may not
+// write the cache either.
+type Context struct {
+	CI     bool
+	DryRun bool
+}
+
+var (
+	mu      sync.RWMutex
+	current Context
+)
+
+// Set installs the context for the rest of the process.
+func Set(ctx Context) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = ctx
+}
+
+// Current returns the context installed by Set.
+func Current() Context {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Allows reports whether op is permitted in the current run. Use it to pick
+// a code path; use Require at the I/O boundary itself.
+func Allows(op Operation) bool {
+	return Require(op) == nil
+}
+
+// Require returns an InvariantViolation error if op is forbidden in the
+// current run. Callers must stop rather than continue without the operation,
+// so a broken invariant always surfaces as a failed run.
+func Require(op Operation) error {
+	ctx := Current()
+	if ctx.CI {
+		return exitcode.New(exitcode.InvariantViolation, "invariant violated: %s is not allowed in CI mode", op)
+	}
+	if ctx.DryRun && op == CacheWrite {
+		return exitcode.New(exitcode.InvariantViolation, "invariant violated: %s is not allowed in a dry run", op)
+	}
+	return nil
+}
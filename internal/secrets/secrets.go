@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+)
+
+// Finding is a likely secret in the content about to be committed.
+type Finding struct {
+	File string
+	Line int
+	Rule string
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return fmt.Sprintf("%s: %s", f.File, f.Rule)
+	}
+	return fmt.Sprintf("%s:%d: %s", f.File, f.Line, f.Rule)
+}
+
+// rule is a named pattern for one kind of secret.
+type rule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+var rules = []rule{
+	{"private key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY( BLOCK)?-----`)},
+	{"AWS access key ID", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abposr]-[0-9A-Za-z-]{10,}\b`)},
+	{"Stripe secret key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{20,}\b`)},
+	{"hard-coded credential", regexp.MustCompile(`(?i)\b(api[_-]?key|secret|token|passw(or)?d)\b["']?\s*[:=]\s*["'][^"'\s]{12,}["']`)},
+}
+
+// sensitiveFiles are file names that should never be committed, whatever
+// they contain.
+var sensitiveFiles = []string{".env", ".env.*", "*.pem", "*.key", "id_rsa", "id_ed25519", ".npmrc", ".pypirc"}
+
+// allowedFiles are templates that share a sensitive name but hold no secrets.
+var allowedFiles = []string{".env.example", ".env.sample", ".env.template"}
+
+// Scan reports likely secrets among the lines a commit would add to path.
+func Scan(path string, added []git.AddedLine) []Finding {
+	var findings []Finding
+
+	base := filepath.Base(path)
+	if len(added) > 0 && isSensitiveFile(base) {
+		findings = append(findings, Finding{File: path, Rule: "sensitive file " + base})
+	}
+
+	for _, line := range added {
+		for _, r := range rules {
+			if r.pattern.MatchString(line.Text) {
+				findings = append(findings, Finding{File: path, Line: line.Number, Rule: r.name})
+			}
+		}
+	}
+	return findings
+}
+
+func isSensitiveFile(base string) bool {
+	for _, allowed := range allowedFiles {
+		if base == allowed {
+			return false
+		}
+	}
+	for _, pattern := range sensitiveFiles {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
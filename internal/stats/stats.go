@@ -0,0 +1,121 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/history"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+)
+
+// Count is how often a type or scope was used, and its share of the
+// conventional commits.
+type Count struct {
+	Name    string  `json:"name"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// Report summarises how a repository's commits are written. Types and
+// scopes come from the learned data; the rest from the analysed commits.
+type Report struct {
+	Commits         int     `json:"commits"`
+	Types           []Count `json:"types"`
+	TopScopes       []Count `json:"top_scopes"`
+	AverageFiles    float64 `json:"average_files"`
+	AverageLines    float64 `json:"average_lines"`
+	Compliant       int     `json:"compliant"`
+	ComplianceRate  float64 `json:"compliance_rate"`
+	Autocommit      int     `json:"autocommit"`
+	AutocommitShare float64 `json:"autocommit_share"`
+}
+
+// Build computes the report. A commit is compliant when it breaks none of
+// the commit rules, and made by autocommit when it carries git.Trailer.
+func Build(learned history.LearnData, commits []git.CommitMessage, rules config.CommitRules, topScopes int) Report {
+	r := Report{Commits: len(commits)}
+
+	conventional := 0
+	for _, n := range learned.Types {
+		conventional += n
+	}
+	r.Types = counts(learned.Types, conventional, 0)
+	r.TopScopes = counts(learned.Scopes, conventional, topScopes)
+
+	files, lines := 0, 0
+	for _, c := range commits {
+		files += len(c.Files)
+		lines += c.Added + c.Deleted
+		if len(lint.Errors(lint.Message(rules, c.Message))) == 0 {
+			r.Compliant++
+		}
+		if git.HasTrailer(c.Message) {
+			r.Autocommit++
+		}
+	}
+	r.AverageFiles = ratio(files, r.Commits)
+	r.AverageLines = ratio(lines, r.Commits)
+	r.ComplianceRate = percent(r.Compliant, r.Commits)
+	r.AutocommitShare = percent(r.Autocommit, r.Commits)
+	return r
+}
+
+// WriteTable prints the report as aligned tables.
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Commits analysed\t%d\n", r.Commits)
+	fmt.Fprintf(tw, "Average size\t%.1f file(s), %.1f line(s)\n", r.AverageFiles, r.AverageLines)
+	fmt.Fprintf(tw, "Conventional compliance\t%d (%.1f%%)\n", r.Compliant, r.ComplianceRate)
+	fmt.Fprintf(tw, "Made by autocommit\t%d (%.1f%%)\n", r.Autocommit, r.AutocommitShare)
+	fmt.Fprintf(tw, "Made by hand\t%d (%.1f%%)\n", r.Commits-r.Autocommit, percent(r.Commits-r.Autocommit, r.Commits))
+
+	for _, section := range []struct {
+		title  string
+		counts []Count
+	}{{"TYPE", r.Types}, {"SCOPE", r.TopScopes}} {
+		fmt.Fprintf(tw, "\n%s\tCOMMITS\tSHARE\n", section.title)
+		if len(section.counts) == 0 {
+			fmt.Fprintf(tw, "(none)\t\t\n")
+		}
+		for _, c := range section.counts {
+			fmt.Fprintf(tw, "%s\t%d\t%.1f%%\n", c.Name, c.Count, c.Percent)
+		}
+	}
+	return tw.Flush()
+}
+
+// counts sorts m by count, most used first, and keeps the top limit
+// entries; limit 0 keeps all of them.
+func counts(m map[string]int, total, limit int) []Count {
+	list := make([]Count, 0, len(m))
+	for name, n := range m {
+		list = append(list, Count{Name: name, Count: n, Percent: percent(n, total)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	if limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
+
+// ratio returns n/d rounded to one decimal place, or 0 when d is 0.
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return math.Round(10*float64(n)/float64(d)) / 10
+}
+
+func percent(n, d int) float64 {
+	return ratio(100*n, d)
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/classify"
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/history"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+const goBefore = `package api
+
+func Get(id int) string {
+	return lookup(id)
+}
+
+func Delete(id int) {}
+
+func lookup(id int) string { return "" }
+`
+
+func TestClassifyGoDeclarations(t *testing.T) {
+	tests := []struct {
+		name, after, wantType, wantReason string
+		breaking                          bool
+	}{
+		{
+			name:       "new exported func",
+			after:      goBefore + "\nfunc List() []string { return nil }\n",
+			wantType:   "feat",
+			wantReason: "adds exported List",
+		},
+		{
+			name:       "added guard",
+			after:      strings.Replace(goBefore, "return lookup(id)", "if id < 0 {\n\t\treturn \"\"\n\t}\n\treturn lookup(id)", 1),
+			wantType:   "fix",
+			wantReason: "adds checks to Get",
+		},
+		{
+			name:       "same signature, new body",
+			after:      strings.Replace(goBefore, "return lookup(id)", "s := lookup(id)\n\treturn s", 1),
+			wantType:   "refactor",
+			wantReason: "changes the body of Get",
+		},
+		{
+			name:       "removed exported func",
+			after:      strings.Replace(goBefore, "func Delete(id int) {}\n", "", 1),
+			wantType:   "refactor",
+			wantReason: "removes exported Delete",
+			breaking:   true,
+		},
+		{
+			name:       "comment only",
+			after:      "// Package api.\n" + goBefore,
+			wantType:   "chore",
+			wantReason: "no keyword in the diff",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newRepo(t, map[string]string{"api.go": goBefore})
+			writeFiles(t, map[string]string{"api.go": tt.after})
+
+			groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), " M api.go", history.LearnData{}, nil)
+			if len(groups) != 1 {
+				t.Fatalf("got %d groups, want 1", len(groups))
+			}
+			g := groups[0]
+			if g.Type != tt.wantType || !strings.Contains(g.Reasons[0], tt.wantReason) || (len(g.Breaking) > 0) != tt.breaking {
+				t.Errorf("got %s %q breaking=%v, want %s %q breaking=%v", g.Type, g.Reasons[0], g.Breaking, tt.wantType, tt.wantReason, tt.breaking)
+			}
+		})
+	}
+}
+
+const apiBefore = `package store
+
+type Config struct {
+	Name string
+	Size int
+}
+
+type Store interface {
+	Get(key string) string
+}
+
+func Open(path string) *Config { return nil }
+
+func Close() {}
+
+func (c *Config) Reset() {}
+`
+
+func TestCompareAPI(t *testing.T) {
+	// Close and Stop share a signature, so only the first by name counts
+	// as renamed to Shutdown. Internal packages have no importers to break.
+	newRepo(t, map[string]string{"store.go": apiBefore + "\nfunc Stop() {}\n", "main.go": "package main\n\nfunc Run() {}\n"})
+	if err := os.MkdirAll("internal/cache", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, map[string]string{"internal/cache/cache.go": "package cache\n\nfunc Get() {}\n"})
+	gitCommit(t, "feat: add cache")
+	after := strings.NewReplacer(
+		"\tSize int\n", "",
+		"Get(key string) string\n", "Get(key string) string\n\tPut(key, value string)\n",
+		"func Open(path string)", "func Open(path string, mode int)",
+		"func Close() {}", "func Shutdown() {}",
+		"func (c *Config) Reset() {}\n", "",
+	).Replace(apiBefore)
+	writeFiles(t, map[string]string{"store.go": after, "main.go": "package main\n", "internal/cache/cache.go": "package cache\n"})
+
+	var got []string
+	for _, c := range classify.CompareAPI([]string{"store.go", "main.go", "internal/cache/cache.go"}) {
+		got = append(got, c.Description)
+	}
+	want := []string{
+		"added method Put to interface store.Store",
+		"changed the signature of store.Open",
+		"removed field store.Config.Size",
+		"removed method store.Config.Reset",
+		"removed store.Stop",
+		"renamed store.Close to store.Shutdown",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("CompareAPI:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestGroupByImports(t *testing.T) {
+	newRepo(t, map[string]string{
+		"go.mod":    "module example.com/m\n\ngo 1.21\n",
+		"notes.txt": "v1\n",
+	})
+	for _, dir := range []string{"store", "app"} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, map[string]string{
+		"store/store.go": "package store\n\nfunc Get() string { return \"\" }\n",
+		"app/app.go":     "package app\n\nimport \"example.com/m/store\"\n\nfunc Run() string { return store.Get() }\n",
+	})
+	gitCommit(t, "feat: add app")
+
+	writeFiles(t, map[string]string{
+		"store/store.go": "package store\n\nfunc Get() string { return \"\" }\n\nfunc Open() {}\n",
+		"app/app.go":     "package app\n\nimport \"example.com/m/store\"\n\nfunc Run() string {\n\tstore.Open()\n\treturn store.Get()\n}\n",
+		"notes.txt":      "v2\n",
+	})
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), " M app/app.go\n M notes.txt\n M store/store.go", history.LearnData{}, nil)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2: %+v", len(groups), groups)
+	}
+	feat := groups[1]
+	if feat.Type != "feat" || strings.Join(feat.Files, " ") != "store/store.go app/app.go" {
+		t.Errorf("got %s %v, want feat [store/store.go app/app.go]", feat.Type, feat.Files)
+	}
+	if !strings.Contains(strings.Join(feat.Reasons, "\n"), "whose new Open it uses") {
+		t.Errorf("merge reason missing: %q", feat.Reasons)
+	}
+}
+
+func TestGroupByImportsMovesOnlyConnectedFiles(t *testing.T) {
+	newRepo(t, map[string]string{"go.mod": "module example.com/m\n\ngo 1.21\n"})
+	for _, dir := range []string{"store", "app", "util"} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFiles(t, map[string]string{
+		"store/store.go": "package store\n\nfunc Get() string { return \"\" }\n",
+		"app/app.go":     "package app\n\nimport \"example.com/m/store\"\n\nfunc Run() string { return store.Get() }\n",
+		"util/u.go":      "package util\n\nfunc U() int { return 1 }\n",
+	})
+	gitCommit(t, "feat: add app")
+
+	writeFiles(t, map[string]string{
+		"store/store.go": "package store\n\nfunc Get() string { return \"\" }\n\nfunc Open() {}\n",
+		"app/app.go":     "package app\n\nimport \"example.com/m/store\"\n\nfunc Run() string {\n\tstore.Open()\n\treturn store.Get()\n}\n",
+		"util/u.go":      "package util\n\nfunc U() int {\n\treturn 2\n}\n",
+	})
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), " M app/app.go\n M store/store.go\n M util/u.go", history.LearnData{}, nil)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want feat and refactor: %+v", len(groups), groups)
+	}
+	feat, refactor := groups[0], groups[1]
+	if feat.Key() != "feat" || strings.Join(feat.Files, " ") != "store/store.go app/app.go" || len(feat.Signals) != 2 {
+		t.Errorf("got %s %v %q, want feat with store/store.go and app/app.go", feat.Key(), feat.Files, feat.Signals)
+	}
+	if feat.Confidence != 0.5 {
+		t.Errorf("feat confidence = %.2f, want 0.50 from the moved app/app.go", feat.Confidence)
+	}
+	if refactor.Key() != "refactor" || strings.Join(refactor.Files, " ") != "util/u.go" || len(refactor.Reasons) != 1 {
+		t.Errorf("got %s %v %q, want refactor with only util/u.go", refactor.Key(), refactor.Files, refactor.Reasons)
+	}
+}
+
+func TestAnalyzersByFileType(t *testing.T) {
+	newRepo(t, map[string]string{"go.mod": "module example.com/m\n"})
+	changes := []string{
+		"?? .github/workflows/ci.yml",
+		" M go.mod",
+		"?? Dockerfile.dev",
+		"?? db/migrations/001_users.sql",
+		"?? config.yaml",
+		"?? README.md",
+	}
+	want := map[string]string{
+		".github/workflows/ci.yml":    "ci",
+		"go.mod":                      "build",
+		"Dockerfile.dev":              "build",
+		"db/migrations/001_users.sql": "feat",
+		"config.yaml":                 "chore",
+		"README.md":                   "docs",
+	}
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), strings.Join(changes, "\n"), history.LearnData{}, nil)
+	got := make(map[string]string)
+	for _, g := range groups {
+		for _, f := range g.Files {
+			got[f] = g.Type
+		}
+	}
+	for file, wantType := range want {
+		if got[file] != wantType {
+			t.Errorf("%s classified as %q, want %q", file, got[file], wantType)
+		}
+	}
+}
+
+func TestClassifyRules(t *testing.T) {
+	newRepo(t, map[string]string{"notes.txt": "a\n", "plain.txt": "a\n", "old.txt": "a\n"})
+	writeFiles(t, map[string]string{"notes.txt": "a\nTODO: b\n", "plain.txt": "a\nb\n", "schema.sql": "create table t;\n"})
+	if err := os.Remove("old.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("db", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, map[string]string{"db/x.sql": "select 1;\n"})
+	rules := []config.ClassifyRule{
+		{Name: "sql", Paths: []string{"*.sql"}, Type: "fix"},
+		{Name: "schema", Paths: []string{"**/schema.sql"}, Status: []string{"added"}, Type: "feat", Scope: "db", Priority: 5},
+		{Paths: []string{"*.txt"}, Diff: `\+TODO`, Type: "docs"},
+		{Status: []string{"deleted"}, Type: "chore", Scope: "cleanup"},
+	}
+	changes := "?? schema.sql\n?? db/x.sql\n M notes.txt\n M plain.txt\n D old.txt"
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), changes, history.LearnData{}, rules)
+	got := make(map[string]string)
+	for _, g := range groups {
+		for _, f := range g.Files {
+			got[f] = g.Key()
+		}
+	}
+	want := map[string]string{
+		"schema.sql": classify.Group{Type: "feat", Scope: "db"}.Key(),
+		"db/x.sql":   classify.Group{Type: "fix"}.Key(),
+		"notes.txt":  classify.Group{Type: "docs"}.Key(),
+		"old.txt":    classify.Group{Type: "chore", Scope: "cleanup"}.Key(),
+	}
+	for file, key := range want {
+		if got[file] != key {
+			t.Errorf("%s grouped as %q, want %q", file, got[file], key)
+		}
+	}
+	if got["plain.txt"] == want["notes.txt"] {
+		t.Errorf("plain.txt matched the diff rule without a TODO")
+	}
+}
+
+func TestClassifyStyleRevertAndBuild(t *testing.T) {
+	newRepo(t, map[string]string{
+		"main.go":   "package main\n\nfunc main() {\n\tprintln(1)\n}\n",
+		"notes.txt": "first\n",
+		"cache.txt": "a\n",
+	})
+	writeFiles(t, map[string]string{"notes.txt": "second\n"})
+	gitCommit(t, "docs: rewrite notes")
+	writeFiles(t, map[string]string{
+		"main.go":   "package main\n\nfunc main()  {\n  println( 1 )\n}\n",
+		"notes.txt": "first\n",
+		"cache.txt": "a\nperformance: keep results\n",
+		"Makefile":  "all:\n\tgo build ./...\n",
+		"build.sh":  "#!/bin/sh\ngo build ./...\n",
+	})
+	changes := " M main.go\n M notes.txt\n M cache.txt\n?? Makefile\n?? build.sh"
+	want := map[string]string{
+		"main.go":   "style",
+		"notes.txt": "revert",
+		"cache.txt": "perf",
+		"Makefile":  "build",
+		"build.sh":  "build",
+	}
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), changes, history.LearnData{}, nil)
+	got := make(map[string]string)
+	for _, g := range groups {
+		for _, f := range g.Files {
+			got[f] = g.Type
+		}
+	}
+	for file, wantType := range want {
+		if got[file] != wantType {
+			t.Errorf("%s classified as %q, want %q", file, got[file], wantType)
+		}
+	}
+}
+
+func TestDeletingAddedFileIsNotRevert(t *testing.T) {
+	newRepo(t, map[string]string{"README.md": "# m\n"})
+	writeFiles(t, map[string]string{"extra.txt": "added\n"})
+	gitCommit(t, "docs: add extra notes")
+	if err := os.Remove("extra.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), " D extra.txt", history.LearnData{}, nil)
+	for _, g := range groups {
+		if g.Type == "revert" {
+			t.Errorf("deleting extra.txt classified as revert: %+v", g)
+		}
+	}
+}
+
+func TestSplitFormattingIntoStyleCommit(t *testing.T) {
+	newRepo(t, map[string]string{
+		"p.go": "package p\n\nimport (\n\t\"strings\"\n\t\"fmt\"\n)\n\nfunc A()  {\n  fmt.Println(strings.ToUpper(\"a\"))\n}\n",
+		"n.c":  "one\ntwo  \nthree\n",
+	})
+	formatted := "package p\n\nimport (\n\t\"fmt\"\n\t\"strings\"\n)\n\nfunc A() {\n\tfmt.Println(strings.ToUpper(\"a\"))\n}\n"
+	writeFiles(t, map[string]string{
+		"p.go": formatted + "\nfunc B() {}\n",
+		"n.c":  "one\ntwo\nthree\nfour\n",
+	})
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), " M p.go\n M n.c", history.LearnData{}, nil)
+	if len(groups) != 3 {
+		t.Fatalf("got %d groups, want style, chore and feat: %+v", len(groups), groups)
+	}
+	style := groups[0]
+	if style.Type != "style" || len(style.Content) != 2 {
+		t.Fatalf("first group = %+v, want the split-off style group", style)
+	}
+	if got := style.Content["p.go"]; got != formatted {
+		t.Errorf("style content of p.go = %q, want %q", got, formatted)
+	}
+	if got, want := style.Content["n.c"], "one\ntwo\nthree\n"; got != want {
+		t.Errorf("style content of n.c = %q, want %q", got, want)
+	}
+	for _, g := range groups[1:] {
+		if len(g.Files) != 1 || len(g.Content) != 0 {
+			t.Errorf("group %s = %+v, want one fully committed file", g.Key(), g)
+		}
+	}
+}
+
+func TestWhitespaceThatMatters(t *testing.T) {
+	files := map[string]string{
+		"app.py":   "def f():\n    if x:\n        a()\n    b()\n",
+		"ci.yml":   "jobs:\n  build:\n    steps: []\n",
+		"Makefile": "all:\n\tgo build\n",
+		"asi.js":   "let a = b\n(c)\n",
+		"msg.c":    "const char *m = \"a  b\";\n",
+		"msg.go":   "package msg\n\nconst M = \"a  b\"\n",
+		"join.c":   "// note\ndo_thing();\n",
+		"note.c":   "// don't  panic\nint x;\n",
+		"ok.c":     "int  n = f( 1 ); // it's fine\n\nint k;\n",
+		"mix.c":    "const char *m = \"a  b\";\nint k = 1;\n",
+	}
+	newRepo(t, files)
+	writeFiles(t, map[string]string{
+		"app.py":   "def f():\n    if x:\n        a()\n        b()\n",
+		"ci.yml":   "jobs:\n  build:\n  steps: []\n",
+		"Makefile": "all:\n        go build\n",
+		"asi.js":   "let a = b(c)\n",
+		"msg.c":    "const char *m = \"a b\";\n",
+		"msg.go":   "package msg\n\nconst M = \"a b\"\n",
+		"join.c":   "// note do_thing();\n",
+		"note.c":   "// don't panic\nint x;\n",
+		"ok.c":     "int n = f(1);   // it's fine\nint k;\n",
+		"mix.c":    "const char *m = \"a b\";\nint k = 1;\nvoid g(void) {}\n",
+	})
+	var changes []string
+	for f := range files {
+		changes = append(changes, " M "+f)
+	}
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), strings.Join(changes, "\n"), history.LearnData{}, nil)
+	for _, g := range groups {
+		for _, f := range g.Files {
+			if (g.Type == "style") != (f == "ok.c") {
+				t.Errorf("%s grouped as %s", f, g.Key())
+			}
+		}
+	}
+}
+
+func TestClassificationConfidence(t *testing.T) {
+	newRepo(t, map[string]string{"api.go": "package api\n", "notes.txt": "a\n"})
+	writeFiles(t, map[string]string{"api.go": "package api\n\nfunc Get() {}\n", "notes.txt": "a\nb\n"})
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), " M api.go\n M notes.txt", history.LearnData{}, nil)
+	want := map[string]struct {
+		confidence float64
+		signal     string
+	}{
+		"feat":  {0.9, "api.go: feat 0.90 (AST)"},
+		"chore": {0.2, "notes.txt: chore 0.20 (fallback)"},
+	}
+	for _, g := range groups {
+		w, ok := want[g.Key()]
+		if !ok {
+			t.Errorf("unexpected group %s", g.Key())
+			continue
+		}
+		if g.Confidence != w.confidence || len(g.Signals) != 1 || g.Signals[0] != w.signal {
+			t.Errorf("group %s: confidence %.2f, signals %q; want %.2f, [%q]", g.Key(), g.Confidence, g.Signals, w.confidence, w.signal)
+		}
+	}
+}
+
+func TestDerivedFilesFollowTheirSource(t *testing.T) {
+	newRepo(t, map[string]string{"go.mod": "module example.com/m\n", "a.go": "package m\n"})
+	if err := os.MkdirAll("vendor/example.com/dep", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, map[string]string{
+		"go.mod":                      "module example.com/m\n\nrequire example.com/dep v1.0.0\n",
+		"go.sum":                      "example.com/dep v1.0.0 h1:abc=\n",
+		"vendor/example.com/dep/d.go": "package dep\n",
+		"a.go":                        "package m\n\nfunc Func() {}\n",
+		"a_string.go":                 "// Code generated by stringer; DO NOT EDIT.\n\npackage m\n\n// fix the bug\nfunc (Kind) String() string { return \"\" }\n",
+		"api.pb.go":                   "package m\n",
+	})
+	changes := " M go.mod\n?? go.sum\n?? vendor/\n M a.go\n?? a_string.go\n?? api.pb.go"
+
+	groups := classify.ClassifyAndGroupChanges(logger.NewQuietLogger(), changes, history.LearnData{}, nil)
+	got := make(map[string]string)
+	for _, g := range groups {
+		for _, f := range g.Files {
+			got[f] = g.Key()
+		}
+	}
+	want := map[string]string{
+		"go.mod":      "build",
+		"go.sum":      "build",
+		"vendor/":     "build",
+		"a.go":        "feat",
+		"a_string.go": "feat",
+		"api.pb.go":   "build(deps)",
+	}
+	for file, key := range want {
+		if got[file] != key {
+			t.Errorf("%s grouped as %q, want %q", file, got[file], key)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+)
+
+func TestParseCommitlintConfigs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	yamlPath := write(".commitlintrc.yaml", `
+extends:
+  - "@commitlint/config-conventional"
+rules:
+  type-enum: [2, always, [feat, fix, chore]]
+  scope-enum: [2, always, [api, cli]]
+  header-max-length: [2, always, 60]
+  subject-case: [2, always, lower-case]
+  body-max-line-length: [1, always, 80]
+`)
+	rules, err := config.ParseCommitGuides([]string{yamlPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"feat", "fix", "chore"}; !reflect.DeepEqual(rules.Types, want) {
+		t.Errorf("Types = %v, want %v", rules.Types, want)
+	}
+	if want := []string{"api", "cli"}; !reflect.DeepEqual(rules.Scopes, want) {
+		t.Errorf("Scopes = %v, want %v", rules.Scopes, want)
+	}
+	if rules.HeaderMaxLength != 60 || rules.BodyMaxLineLength != 80 || rules.FooterMaxLineLength != 100 {
+		t.Errorf("lengths = %d/%d/%d, want 60/80/100", rules.HeaderMaxLength, rules.BodyMaxLineLength, rules.FooterMaxLineLength)
+	}
+	if want := (config.CaseRule{Cases: []string{"lower-case"}}); !reflect.DeepEqual(rules.SubjectCase, want) {
+		t.Errorf("SubjectCase = %+v, want %+v", rules.SubjectCase, want)
+	}
+	if !rules.Warnings["body-max-line-length"] {
+		t.Errorf("body-max-line-length should be a warning")
+	}
+
+	pkgPath := write("package.json", `{"name": "x", "commitlint": {"rules": {"type-enum": [0], "scope-empty": [2, "never"]}}}`)
+	rules, err = config.ParseCommitGuides([]string{pkgPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules.Types != nil || !rules.ScopeRequired {
+		t.Errorf("package.json rules not applied: Types=%v ScopeRequired=%v", rules.Types, rules.ScopeRequired)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+)
+
+// writeUserConfig writes the user-global config file under dir.
+func writeUserConfig(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "autocommit", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	t.Chdir(t.TempDir())
+	user := writeUserConfig(t, xdg, "auto_push = false\nverbose = true\nreview_mode = true\n")
+	if err := os.WriteFile(config.RepoConfigFile, []byte("verbose = false\nreview_mode = false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AUTOCOMMIT_REVIEW_MODE", "true")
+	t.Setenv("AUTOCOMMIT_AI_COMMIT", "true")
+
+	cfg, err := config.LoadConfig(map[string]string{"ai_commit": "false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		key, value, origin string
+	}{
+		{"learn_from_history", "true", "default"},
+		{"auto_push", "false", "user " + user},
+		{"verbose", "false", "repo " + config.RepoConfigFile},
+		{"review_mode", "true", "env AUTOCOMMIT_REVIEW_MODE"},
+		{"ai_commit", "false", "flag"},
+	}
+	for _, tt := range tests {
+		if got := cfg.Get(tt.key); got != tt.value {
+			t.Errorf("%s = %s, want %s", tt.key, got, tt.value)
+		}
+		if got := cfg.Origins[tt.key]; got != tt.origin {
+			t.Errorf("origin of %s = %q, want %q", tt.key, got, tt.origin)
+		}
+	}
+
+	t.Setenv("AUTOCOMMIT_VERBOSE", "maybe")
+	if _, err := config.LoadConfig(nil); err == nil {
+		t.Error("LoadConfig accepted AUTOCOMMIT_VERBOSE=maybe")
+	}
+}
+
+func TestUserConfigWithoutXDG(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Chdir(t.TempDir())
+	user := writeUserConfig(t, filepath.Join(home, ".config"), "auto_push = false\n")
+
+	if got := config.UserConfigPath(); got != user {
+		t.Errorf("UserConfigPath() = %q, want %q", got, user)
+	}
+	cfg, err := config.LoadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.AutoPush || cfg.Origins["auto_push"] != "user "+user {
+		t.Errorf("auto_push = %v from %q, want false from the user file", cfg.AutoPush, cfg.Origins["auto_push"])
+	}
+	if got := cfg.Origins["ai_commit"]; got != "default" {
+		t.Errorf("origin of ai_commit = %q, want default", got)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/exitcode"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+		name string
+	}{
+		{nil, 0, "success"},
+		{errors.New("boom"), 1, "error"},
+		{exitcode.New(exitcode.NoChanges, "clean"), 2, "no_changes"},
+		{exitcode.New(exitcode.UnsafeGitState, "detached HEAD"), 3, "unsafe_git_state"},
+		{exitcode.Wrap(exitcode.AIFailure, errors.New("timeout")), 4, "ai_failure"},
+		{exitcode.New(exitcode.RuleViolation, "bad message"), 5, "rule_violation"},
+		{exitcode.New(exitcode.PushRejected, "rejected"), 6, "push_rejected"},
+		{exitcode.New(exitcode.SecretDetected, "key"), 7, "secret_detected"},
+		{exitcode.New(exitcode.InvariantViolation, "stdin in CI"), 8, "invariant_violation"},
+		// The kind survives further wrapping.
+		{fmt.Errorf("apply: %w", exitcode.New(exitcode.UnsafeGitState, "moved")), 3, "unsafe_git_state"},
+	}
+	for _, tt := range tests {
+		kind := exitcode.KindOf(tt.err)
+		if kind.Code() != tt.code || kind.String() != tt.name {
+			t.Errorf("KindOf(%v) = %d %s, want %d %s", tt.err, kind.Code(), kind, tt.code, tt.name)
+		}
+	}
+	if exitcode.Wrap(exitcode.AIFailure, nil) != nil {
+		t.Error("Wrap(nil) is not nil")
+	}
+}
+
+// TestJSONResult runs Result in a child process, since it exits, and checks
+// the final line of output.
+func TestJSONResult(t *testing.T) {
+	if os.Getenv("AUTOCOMMIT_TEST_RESULT") == "1" {
+		log := logger.NewJSONLogger(false)
+		log.Info("working")
+		kind := exitcode.KindOf(exitcode.New(exitcode.SecretDetected, "found 1 possible secret"))
+		log.Result(kind.Code(), kind.String(), "Error: %v", "found 1 possible secret")
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestJSONResult$")
+	cmd.Env = append(os.Environ(), "AUTOCOMMIT_TEST_RESULT=1")
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 7 {
+		t.Fatalf("exit: %v, want code 7\n%s", err, out)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	var entry struct {
+		Level    string `json:"level"`
+		Message  string `json:"message"`
+		ExitCode *int   `json:"exit_code"`
+		Result   string `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil {
+		t.Fatalf("last line is not JSON: %v\n%s", err, out)
+	}
+	if entry.Level != "result" || entry.ExitCode == nil || *entry.ExitCode != 7 || entry.Result != "secret_detected" || entry.Message != "Error: found 1 possible secret" {
+		t.Errorf("result entry = %+v", entry)
+	}
+	if len(lines) != 2 {
+		t.Errorf("got %d lines, want the info line and the result line:\n%s", len(lines), out)
+	}
+}
+
+func TestCommandsEndWithResult(t *testing.T) {
+	binary := buildCLI(t)
+	newRepo(t, map[string]string{"a.txt": "a\n"})
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	for _, args := range [][]string{
+		{"config", "show"},
+		{"stats"},
+		{"hook", "install"},
+	} {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			out, err := exec.Command(binary, append([]string{"--ci"}, args...)...).Output()
+			if err != nil {
+				t.Fatalf("%v\n%s", err, out)
+			}
+			lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+			var entry struct {
+				Level  string `json:"level"`
+				Result string `json:"result"`
+			}
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &entry); err != nil || entry.Level != "result" || entry.Result != "success" {
+				t.Errorf("last line %q is not the success result (%v)", lines[len(lines)-1], err)
+			}
+		})
+	}
+}
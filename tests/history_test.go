@@ -0,0 +1,259 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/history"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/plan"
+	"github.com/urstruelysv/autocommit-cli/internal/runctx"
+)
+
+func TestPathTriePredictsScope(t *testing.T) {
+	trie := &history.PathNode{}
+	trie.Add("internal/lint/lint.go", "feat", "rules")
+	trie.Add("internal/lint/case.go", "fix", "rules")
+	trie.Add("internal/lint/repair.go", "fix", "rules")
+	trie.Add("internal/git/git.go", "fix", "git")
+	trie.Add("internal/git/git.go", "feat", "git")
+	trie.Add("README.md", "docs", "")
+
+	tests := []struct {
+		path     string
+		scope    string
+		wantType string
+	}{
+		{"internal/lint/new.go", "rules", "fix"},
+		{"internal/git/git.go", "git", ""},
+		{"internal/other.go", "", ""},
+		{"cmd/main.go", "", ""},
+	}
+	for _, tt := range tests {
+		if scope, _ := trie.PredictScope(tt.path); scope != tt.scope {
+			t.Errorf("PredictScope(%q) = %q, want %q", tt.path, scope, tt.scope)
+		}
+		if typ, _ := trie.PredictType(tt.path); typ != tt.wantType {
+			t.Errorf("PredictType(%q) = %q, want %q", tt.path, typ, tt.wantType)
+		}
+	}
+
+	var empty *history.PathNode
+	if scope, _ := empty.PredictScope("internal/lint/lint.go"); scope != "" {
+		t.Errorf("nil trie predicted %q", scope)
+	}
+}
+
+func TestStyleFromSubjects(t *testing.T) {
+	var style history.Style
+	for _, subject := range []string{
+		"feat: Added user export.",
+		"feat(api): Added pagination.",
+		"fix: Resolved crash on empty input.",
+		"fix: Resolved race in cache.",
+		"docs: Updated README.",
+		"Merge branch 'main'",
+	} {
+		style.Observe(subject)
+	}
+
+	if style.Subjects != 5 {
+		t.Fatalf("Subjects = %d, want 5", style.Subjects)
+	}
+	if style.Mood() != history.PastTense || !style.Capitalise() || !style.EndWithFullStop() {
+		t.Errorf("mood %q, capitalise %v, full stop %v", style.Mood(), style.Capitalise(), style.EndWithFullStop())
+	}
+	var none config.CommitRules
+	if got, want := style.Phrase(none, "fix", "fix bugs"), "Resolved bugs."; got != want {
+		t.Errorf("Phrase(fix) = %q, want %q", got, want)
+	}
+	if got, want := style.Phrase(none, "chore", "maintenance"), "Maintenance."; got != want {
+		t.Errorf("Phrase(chore) = %q, want %q", got, want)
+	}
+	if got := style.Guidance(none); !strings.Contains(got, "past tense") || !strings.Contains(got, "Common verbs for fix: resolve.") || !strings.Contains(got, "capital letter") {
+		t.Errorf("Guidance() = %q", got)
+	}
+
+	// The default rules forbid sentence-case and a trailing full stop, so
+	// only the mood and verbs of the style apply.
+	rules := config.DefaultCommitRules()
+	phrased := style.Phrase(rules, "fix", "fix bugs")
+	if want := "resolved bugs"; phrased != want {
+		t.Errorf("Phrase(fix) under default rules = %q, want %q", phrased, want)
+	}
+	if v := lint.Errors(lint.Message(rules, "fix: "+phrased)); len(v) != 0 {
+		t.Errorf("phrased subject breaks the default rules: %v", v)
+	}
+	guidance := style.Guidance(rules)
+	if !strings.Contains(guidance, "past tense") || strings.Contains(guidance, "capital") || strings.Contains(guidance, "full stop") {
+		t.Errorf("Guidance() under default rules = %q", guidance)
+	}
+
+	var unknown history.Style
+	unknown.Observe("feat: added export")
+	if got := unknown.Phrase(none, "feat", "add new functionality"); got != "add new functionality" {
+		t.Errorf("Phrase with too little history = %q", got)
+	}
+}
+
+func TestPhraseBuiltInSummaries(t *testing.T) {
+	// The rule-based summaries of cmd/autocommit-cli, by type.
+	summaries := map[string]string{
+		"feat":     "add new functionality",
+		"fix":      "fix bugs",
+		"docs":     "update documentation",
+		"style":    "format code",
+		"refactor": "refactor code",
+		"perf":     "improve performance",
+		"test":     "update tests",
+		"build":    "update build configuration",
+		"ci":       "update CI workflows",
+		"chore":    "maintenance",
+		"revert":   "revert earlier changes",
+	}
+	tests := []struct {
+		verb string
+		want map[string]string
+	}{
+		{"added", map[string]string{
+			"feat": "added new functionality", "fix": "fixed bugs", "docs": "updated documentation",
+			"style": "formatted code", "refactor": "refactored code", "perf": "improved performance",
+			"test": "updated tests", "build": "updated build configuration", "ci": "updated CI workflows",
+			"chore": "maintenance", "revert": "reverted earlier changes",
+		}},
+		{"adds", map[string]string{
+			"feat": "adds new functionality", "fix": "fixes bugs", "docs": "updates documentation",
+			"style": "formats code", "refactor": "refactors code", "perf": "improves performance",
+			"test": "updates tests", "build": "updates build configuration", "ci": "updates CI workflows",
+			"chore": "maintenance", "revert": "reverts earlier changes",
+		}},
+	}
+	for _, tt := range tests {
+		var style history.Style
+		for i := 0; i < 5; i++ {
+			style.Observe("chore: " + tt.verb + " item")
+		}
+		for typ, summary := range summaries {
+			if got := style.Phrase(config.CommitRules{}, typ, summary); got != tt.want[typ] {
+				t.Errorf("Phrase(%s, %q) in the mood of %q = %q, want %q", typ, summary, tt.verb, got, tt.want[typ])
+			}
+		}
+	}
+}
+
+func TestMigrateLegacyCache(t *testing.T) {
+	legacy := `{"scopes":{"api":3},"types":{"feat":2}}`
+	tests := []struct {
+		name        string
+		tracked     bool
+		learned     string
+		wantScope   string
+		wantRemoved bool
+	}{
+		{"untracked legacy file", false, "", "api", true},
+		{"tracked legacy file", true, "", "api", false},
+		{"learned.json already exists", false, `{"version":3,"scopes":{"cli":1}}`, "cli", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newRepo(t, map[string]string{"a.txt": "a\n"})
+			writeFiles(t, map[string]string{".autocommit_cache": legacy})
+			if tt.tracked {
+				gitCommit(t, "chore: track the cache")
+			}
+			if tt.learned != "" {
+				if err := os.MkdirAll(".git/autocommit", 0755); err != nil {
+					t.Fatal(err)
+				}
+				writeFiles(t, map[string]string{".git/autocommit/learned.json": tt.learned})
+			}
+
+			data, err := history.LoadLearnedData(logger.NewQuietLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := data.Scopes[tt.wantScope]; !ok || len(data.Scopes) != 1 {
+				t.Errorf("scopes = %v, want only %q", data.Scopes, tt.wantScope)
+			}
+			_, err = os.Stat(".autocommit_cache")
+			if removed := os.IsNotExist(err); removed != tt.wantRemoved {
+				t.Errorf("legacy file removed = %v, want %v", removed, tt.wantRemoved)
+			}
+			if _, err := os.Stat(".git/autocommit/learned.json"); err != nil {
+				t.Errorf("learned.json was not written: %v", err)
+			}
+			if out, _ := exec.Command("git", "status", "--porcelain").Output(); tt.tracked && len(out) != 0 {
+				t.Errorf("tracked legacy file changed in the working tree: %s", out)
+			}
+		})
+	}
+}
+
+func TestReadsDoNotCreateStateDir(t *testing.T) {
+	defer runctx.Set(runctx.Context{})
+	for _, ctx := range []runctx.Context{{CI: true}, {DryRun: true}} {
+		newRepo(t, map[string]string{"a.txt": "a\n"})
+		writeFiles(t, map[string]string{".autocommit_cache": `{"scopes":{"api":3}}`})
+		runctx.Set(ctx)
+
+		if data, err := history.LoadLearnedData(logger.NewQuietLogger()); err != nil || data.Scopes["api"] != 3 {
+			t.Errorf("%+v: LoadLearnedData() = %v, %v, want the legacy data", ctx, data.Scopes, err)
+		}
+		if _, err := plan.Load(); err == nil {
+			t.Errorf("%+v: plan.Load() found a plan in a new repository", ctx)
+		}
+		if _, err := os.Stat(".git/autocommit"); !os.IsNotExist(err) {
+			t.Errorf("%+v: reading state created .git/autocommit (%v)", ctx, err)
+		}
+	}
+}
+
+func TestUpdateFromHistory(t *testing.T) {
+	log := logger.NewQuietLogger()
+	newRepo(t, map[string]string{"a.txt": "a\n"})
+	writeFiles(t, map[string]string{"a.txt": "b\n"})
+	gitCommit(t, "feat(api): add a")
+
+	data := history.LearnFromHistory(log)
+	// A count no commit produces shows whether data was kept or rebuilt.
+	data.Scopes["legacy"] = 4
+
+	writeFiles(t, map[string]string{"a.txt": "c\n"})
+	gitCommit(t, "fix(api): handle b")
+	writeFiles(t, map[string]string{"README.md": "# m\n"})
+	gitCommit(t, "docs(readme): describe c")
+
+	data, changed := history.UpdateFromHistory(log, data)
+	if !changed {
+		t.Fatal("UpdateFromHistory reported no change after two new commits")
+	}
+	if data.Scopes["legacy"] != 4 || data.Scopes["api"] != 2 || data.Scopes["readme"] != 1 || data.Types["fix"] != 1 || data.Types["feat"] != 1 {
+		t.Errorf("incremental update: scopes %v, types %v", data.Scopes, data.Types)
+	}
+	if head := gitOutput(t, "rev-parse", "HEAD"); data.LastCommit != head {
+		t.Errorf("LastCommit = %q, want HEAD %q", data.LastCommit, head)
+	}
+	if _, changed := history.UpdateFromHistory(log, data); changed {
+		t.Error("UpdateFromHistory changed data already at HEAD")
+	}
+
+	// Rewrite history: the last commit seen is no longer an ancestor.
+	gitOutput(t, "reset", "-q", "--hard", "HEAD~2")
+	writeFiles(t, map[string]string{"a.txt": "d\n"})
+	gitCommit(t, "perf(db): speed up d")
+
+	data, changed = history.UpdateFromHistory(log, data)
+	if !changed {
+		t.Fatal("UpdateFromHistory reported no change after history was rewritten")
+	}
+	if _, ok := data.Scopes["legacy"]; ok || data.Scopes["readme"] != 0 || data.Scopes["db"] != 1 || data.Types["fix"] != 0 || data.Types["perf"] != 1 {
+		t.Errorf("rebuild: scopes %v, types %v", data.Scopes, data.Types)
+	}
+	if head := gitOutput(t, "rev-parse", "HEAD"); data.LastCommit != head {
+		t.Errorf("LastCommit = %q, want HEAD %q", data.LastCommit, head)
+	}
+}
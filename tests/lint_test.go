@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/lint"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+)
+
+func TestLintMessage(t *testing.T) {
+	rules := config.DefaultCommitRules()
+	rules.Scopes = []string{"git", "cli"}
+
+	cases := []struct {
+		message string
+		want    []string
+	}{
+		{"feat(git): add push retries", nil},
+		{"fix(cli,git): handle empty input", nil},
+		{"Feat: Add things", []string{"type-case", "type-enum", "subject-case"}},
+		{"feat(web): add page", []string{"scope-enum"}},
+		{"update readme", []string{"header-format"}},
+		{"docs: ", []string{"header-format"}},
+		{"chore: " + strings.Repeat("a", 80), []string{"header-max-length"}},
+	}
+
+	for _, c := range cases {
+		var got []string
+		for _, v := range lint.Message(rules, c.message) {
+			got = append(got, v.Rule)
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("lint.Message(%q) = %v, want %v", c.message, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("lint.Message(%q) = %v, want %v", c.message, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+func TestLintIgnored(t *testing.T) {
+	cases := []struct {
+		message string
+		want    bool
+	}{
+		{"Merge branch 'main' into feature", true},
+		{"Merge pull request #12 from user/branch\n\nAdd things", true},
+		{"Merge remote-tracking branch 'origin/main'", true},
+		{"Merge tag 'v1.2.0'", true},
+		{"Revert \"feat: add push retries\"\n\nThis reverts commit abc.", true},
+		{"fixup! feat: add push retries", true},
+		{"squash! fix: trim input", true},
+		{"revert: undo push retries", false},
+		{"feat: merge branch configs", false},
+		{"Update readme", false},
+	}
+	rules := config.DefaultCommitRules()
+	for _, c := range cases {
+		if got := lint.Ignored(c.message); got != c.want {
+			t.Errorf("lint.Ignored(%q) = %v, want %v", c.message, got, c.want)
+		}
+		// Every ignored message would otherwise fail the default rules.
+		if c.want && len(lint.Errors(lint.Message(rules, c.message))) == 0 {
+			t.Errorf("%q passes the default rules; it does not need ignoring", c.message)
+		}
+	}
+}
+
+func TestCleanMessage(t *testing.T) {
+	raw := "fix: trim input\n\nbody line\n# Please enter the commit message\n# ------------------------ >8 ------------------------\ndiff --git a/x b/x\n"
+	if got, want := lint.CleanMessage(raw), "fix: trim input\n\nbody line"; got != want {
+		t.Errorf("CleanMessage() = %q, want %q", got, want)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	rules := config.DefaultCommitRules()
+	rules.Scopes = []string{"git"}
+
+	cases := []struct {
+		message string
+		want    string
+	}{
+		{"```\nFeature(git,web): Add push retries.\n```", "feat(git): add push retries"},
+		{"\"fix(web): handle empty input\"", "fix: handle empty input"},
+		{"refactor: " + strings.Repeat("word ", 20), "refactor: word word word word word word word word word word word word"},
+	}
+
+	for _, c := range cases {
+		if got := lint.Repair(rules, c.message); got != c.want {
+			t.Errorf("Repair(%q) = %q, want %q", c.message, got, c.want)
+		}
+	}
+
+	// Types are lower-cased even when no type-case rule is configured.
+	if got := lint.Repair(config.CommitRules{}, "Feat: add x"); got != "feat: add x" {
+		t.Errorf("Repair without rules = %q, want %q", got, "feat: add x")
+	}
+}
+
+func TestEnforceFallsBack(t *testing.T) {
+	rules := config.DefaultCommitRules()
+	log := logger.NewJSONLogger(false)
+
+	if got := lint.Enforce(log, rules, "Updated some files"); got != lint.FallbackMessage {
+		t.Errorf("Enforce() = %q, want %q", got, lint.FallbackMessage)
+	}
+	if got := lint.Enforce(log, rules, "docs: update readme"); got != "docs: update readme" {
+		t.Errorf("Enforce() = %q, want message unchanged", got)
+	}
+}
+
+func TestMarkBreaking(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"feat(api): add export", "feat(api)!: add export\n\nBREAKING CHANGE: removed api.Get; changed the signature of api.Put"},
+		{"fix: handle nil\n\nBody text.", "fix!: handle nil\n\nBody text.\n\nBREAKING CHANGE: removed api.Get; changed the signature of api.Put"},
+		{"fix!: handle nil\n\nBREAKING CHANGE: Get is gone", "fix!: handle nil\n\nBREAKING CHANGE: Get is gone"},
+		{"not conventional", "not conventional"},
+	}
+	rules := config.DefaultCommitRules()
+	for _, tt := range tests {
+		if got := lint.MarkBreaking(rules, tt.in, []string{"removed api.Get", "changed the signature of api.Put"}); got != tt.want {
+			t.Errorf("MarkBreaking(%q) =\n%q\nwant\n%q", tt.in, got, tt.want)
+		}
+	}
+
+	rules.FooterMaxLineLength = 40
+	got := lint.MarkBreaking(rules, "feat(api): add export", []string{"removed api.Get", "changed the signature of api.Put"})
+	if want := "feat(api)!: add export\n\nBREAKING CHANGE: removed api.Get;\nchanged the signature of api.Put"; got != want {
+		t.Errorf("MarkBreaking with footer-max-line-length 40 =\n%q\nwant\n%q", got, want)
+	}
+	if v := lint.Message(rules, got); len(v) != 0 {
+		t.Errorf("wrapped message breaks the rules: %v", v)
+	}
+}
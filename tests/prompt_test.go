@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/prompt"
+)
+
+func TestBuildPrompt(t *testing.T) {
+	examples := []git.CommitMessage{
+		{Hash: "a", Message: "fix(lint): handle empty scopes\n\nScopes like `()` no longer crash the parser."},
+		{Hash: "b", Message: "feat(lint): add subject-case rule"},
+	}
+	got := prompt.Build("diff --git a/x b/x", "Match the style of this repository's commit history:", examples, nil)
+
+	for _, want := range []string{
+		"fix(lint): handle empty scopes\n\nScopes like `()` no longer crash the parser.",
+		"feat(lint): add subject-case rule",
+		"Match the style of this repository's commit history:",
+		"Diff:\ndiff --git a/x b/x",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt is missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "handle empty scopes") > strings.Index(got, "add subject-case rule") {
+		t.Error("examples are not in relevance order")
+	}
+
+	if got := prompt.Build("d", "", nil, nil); !strings.Contains(got, "Example: feat: add new user authentication endpoint") {
+		t.Errorf("prompt without examples lost the default example:\n%s", got)
+	}
+
+	long := []git.CommitMessage{{Hash: "c", Message: "fix: " + strings.Repeat("é", 1000)}}
+	if got := prompt.Build("d", "", long, nil); !utf8.ValidString(got) || !strings.Contains(got, "é\n[...]") {
+		t.Errorf("long example was not cut on a rune boundary:\n%s", got)
+	}
+}
+
+func TestExamples(t *testing.T) {
+	newRepo(t, map[string]string{"README.md": "# m\n"})
+	for _, dir := range []string{"api", "db"} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []struct{ file, message string }{
+		{"api/handler.go", "feat(api): add handler"},
+		{"db/query.go", "fix(db): fix query"},
+		{"notes.md", "docs: update notes"},
+		{"api/handler.go", "Update api handler"},
+		{"api/routes.go", "refactor(api): split routes"},
+	} {
+		writeFiles(t, map[string]string{c.file: c.message + "\n"})
+		gitCommit(t, c.message)
+	}
+
+	tests := []struct {
+		name  string
+		query prompt.Query
+		n     int
+		want  []string
+	}{
+		{
+			name:  "shared file first, non-conventional skipped",
+			query: prompt.Query{Files: []string{"api/handler.go"}},
+			n:     3,
+			want:  []string{"feat(api): add handler", "refactor(api): split routes"},
+		},
+		{
+			name:  "matching type",
+			query: prompt.Query{Files: []string{"api/other.go"}, Type: "feat"},
+			n:     3,
+			want:  []string{"feat(api): add handler", "refactor(api): split routes"},
+		},
+		{
+			name:  "matching scope, ties newest first",
+			query: prompt.Query{Files: []string{"api/other.go", "db/other.go"}, Scope: "db"},
+			n:     3,
+			want:  []string{"fix(db): fix query", "refactor(api): split routes", "feat(api): add handler"},
+		},
+		{
+			name:  "limited to n",
+			query: prompt.Query{Files: []string{"api/handler.go"}},
+			n:     1,
+			want:  []string{"feat(api): add handler"},
+		},
+		{
+			name:  "n=0",
+			query: prompt.Query{Files: []string{"api/handler.go"}},
+			n:     0,
+		},
+		{
+			name:  "nothing related",
+			query: prompt.Query{Files: []string{"web/page.go"}, Type: "feat"},
+			n:     3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range prompt.Examples(logger.NewQuietLogger(), tt.query, tt.n) {
+				got = append(got, c.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Examples() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
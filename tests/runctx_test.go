@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/exitcode"
+	"github.com/urstruelysv/autocommit-cli/internal/runctx"
+)
+
+func TestRunContextInvariants(t *testing.T) {
+	defer runctx.Set(runctx.Context{})
+
+	ops := []runctx.Operation{runctx.CacheWrite, runctx.StdinRead, runctx.Editor, runctx.Banner}
+
+	runctx.Set(runctx.Context{})
+	for _, op := range ops {
+		if err := runctx.Require(op); err != nil {
+			t.Errorf("interactive run: %s should be allowed, got %v", op, err)
+		}
+	}
+
+	runctx.Set(runctx.Context{CI: true})
+	for _, op := range ops {
+		err := runctx.Require(op)
+		if exitcode.KindOf(err) != exitcode.InvariantViolation {
+			t.Errorf("CI run: %s should be an invariant violation, got %v", op, err)
+		}
+	}
+
+	runctx.Set(runctx.Context{DryRun: true})
+	if runctx.Allows(runctx.CacheWrite) {
+		t.Error("dry run: cache writes should be forbidden")
+	}
+	if !runctx.Allows(runctx.StdinRead) {
+		t.Error("dry run: stdin reads should be allowed")
+	}
+}
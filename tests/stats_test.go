@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urstruelysv/autocommit-cli/internal/config"
+	"github.com/urstruelysv/autocommit-cli/internal/git"
+	"github.com/urstruelysv/autocommit-cli/internal/history"
+	"github.com/urstruelysv/autocommit-cli/internal/logger"
+	"github.com/urstruelysv/autocommit-cli/internal/stats"
+)
+
+func TestStats(t *testing.T) {
+	log := logger.NewQuietLogger()
+	newRepo(t, map[string]string{"a.txt": "a\n"})
+	writeFiles(t, map[string]string{"a.txt": "b\n"})
+	gitCommit(t, "feat(api): add export\n\n"+git.Trailer)
+	writeFiles(t, map[string]string{"a.txt": "c\n"})
+	gitCommit(t, "fix(api): handle nil")
+	writeFiles(t, map[string]string{"README.md": "# m\n"})
+	gitCommit(t, "Update readme")
+
+	commits, err := git.CommitsWithFiles(log, "--no-merges")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := stats.Build(history.LearnFromHistory(log), commits, config.DefaultCommitRules(), 5)
+
+	wantTypes := []stats.Count{{Name: "chore", Count: 1, Percent: 33.3}, {Name: "feat", Count: 1, Percent: 33.3}, {Name: "fix", Count: 1, Percent: 33.3}}
+	if !reflect.DeepEqual(r.Types, wantTypes) {
+		t.Errorf("Types = %v, want %v", r.Types, wantTypes)
+	}
+	if want := []stats.Count{{Name: "api", Count: 2, Percent: 66.7}}; !reflect.DeepEqual(r.TopScopes, want) {
+		t.Errorf("TopScopes = %v, want %v", r.TopScopes, want)
+	}
+	if r.Commits != 4 || r.Compliant != 3 || r.ComplianceRate != 75 || r.Autocommit != 1 || r.AutocommitShare != 25 {
+		t.Errorf("report = %+v, want 4 commits, 3 (75%%) compliant, 1 (25%%) by autocommit", r)
+	}
+	if r.AverageFiles != 1 || r.AverageLines != 1.5 {
+		t.Errorf("average size = %.1f file(s), %.1f line(s), want 1.0 and 1.5", r.AverageFiles, r.AverageLines)
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]interface{}{"commits": 4.0, "compliance_rate": 75.0, "autocommit_share": 25.0} {
+		if decoded[key] != want {
+			t.Errorf("JSON %s = %v, want %v", key, decoded[key], want)
+		}
+	}
+	if types, ok := decoded["types"].([]interface{}); !ok || len(types) != 3 {
+		t.Errorf("JSON types = %v", decoded["types"])
+	}
+
+	var table bytes.Buffer
+	if err := r.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Conventional compliance  3 (75.0%)", "Made by autocommit       1 (25.0%)"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("table is missing %q:\n%s", want, table.String())
+		}
+	}
+}